@@ -3,20 +3,59 @@ Package direwolf is a convenient and easy to use http client written in Golang.
 */
 package direwolf
 
-// Default global session
-var defatultSession *Session
+import (
+	"sync"
+)
+
+// Default global session, it is used by the package level methods.
+var (
+	defaultSessionMu sync.RWMutex
+	defaultSession   *Session
+)
 
 func init() {
 	sessionOptions := DefaultSessionOptions() // New default global session
 	sessionOptions.DisableCookieJar = true
-	defatultSession = NewSession(sessionOptions)
+	defaultSession = NewSession(sessionOptions)
+}
+
+// DefaultSession returns the global session used by the package level methods,
+// such as Get and Post. You can change its headers and proxy by the setters.
+func DefaultSession() *Session {
+	defaultSessionMu.RLock()
+	defer defaultSessionMu.RUnlock()
+	return defaultSession
+}
+
+// SetDefaultSession replaces the global session used by the package level
+// methods, and returns the previous one, so that it can be restored. It is
+// useful to swap in a stub session in tests.
+func SetDefaultSession(session *Session) *Session {
+	defaultSessionMu.Lock()
+	defer defaultSessionMu.Unlock()
+	previous := defaultSession
+	defaultSession = session
+	return previous
+}
+
+// ConfigureDefault replaces the global session with a new Session built by
+// the options, such as the BaseURL, Headers, Proxy, TLSClientConfig, Retry
+// and timeouts. Note that the cookie jar is enabled unless DisableCookieJar
+// is set, the default global session disables it.
+func ConfigureDefault(options *SessionOptions) error {
+	session := NewSession(options)
+	if session == nil {
+		return WrapErr(ErrSessionOptions, "configure default session failed")
+	}
+	SetDefaultSession(session)
+	return nil
 }
 
 // Send is different with Get and Post method, you should pass a
 // Request to it. You can construct Request by use NewRequest
 // method.
 func Send(req *Request) (*Response, error) {
-	resp, err := defatultSession.Send(req)
+	resp, err := DefaultSession().Send(req)
 	if err != nil {
 		return nil, err
 	}
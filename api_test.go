@@ -1,6 +1,10 @@
 package direwolf
 
 import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -168,3 +172,62 @@ func TestSendParams(t *testing.T) {
 		t.Fatal("request params test failed")
 	}
 }
+
+// stubTransport answers every request with the request method and url.
+type stubTransport struct{}
+
+func (stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: 200,
+		Proto:      "HTTP/1.1",
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader("stub " + req.Method + " " + req.URL.String())),
+		Request:    req,
+	}, nil
+}
+
+func TestSetDefaultSession(t *testing.T) {
+	options := DefaultSessionOptions()
+	options.Transport = stubTransport{}
+	previous := SetDefaultSession(NewSession(options))
+	defer SetDefaultSession(previous)
+
+	resp, err := Get("http://direwolf.invalid/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "stub GET http://direwolf.invalid/test" {
+		t.Fatal("SetDefaultSession failed: ", resp.Text())
+	}
+}
+
+func TestConfigureDefault(t *testing.T) {
+	ts := newTestSessionServer()
+	defer ts.Close()
+	previous := DefaultSession()
+	defer SetDefaultSession(previous)
+
+	options := DefaultSessionOptions()
+	options.BaseURL = ts.URL
+	options.Headers = http.Header{"User-Agent": {"configured"}}
+	options.Proxy = &Proxy{HTTP: "http://127.0.0.1:8888"}
+	if err := ConfigureDefault(options); err != nil {
+		t.Fatal(err)
+	}
+	if proxy := DefaultSession().GetProxy(); proxy == nil || proxy.HTTP != "http://127.0.0.1:8888" {
+		t.Fatal("ConfigureDefault failed: ", proxy)
+	}
+	DefaultSession().SetProxy(nil)
+	resp, err := Get("/getHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "configured" {
+		t.Fatal("ConfigureDefault failed: ", resp.Text())
+	}
+
+	options.BaseURL = "http://[::1"
+	if err := ConfigureDefault(options); !errors.Is(err, ErrSessionOptions) {
+		t.Fatal("ConfigureDefault failed: ", err)
+	}
+}
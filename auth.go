@@ -0,0 +1,329 @@
+package direwolf
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxAuthRounds is the max number of requests sent for one authentication.
+const maxAuthRounds = 3
+
+// Auth is the authentication of requests, one of the Request Options.
+// BasicAuth, DigestAuth, NTLMAuth, OAuth2, JWTAuth, AWSSigV4 and HTTPSignature
+// implement it. It can be set as the Session default by Session.With.
+//
+// The credentials are only sent to the host of the original request, they
+// are removed when redirect to another host.
+type Auth interface {
+	RequestOption
+	// newAuthorizer returns an authorizer to authenticate a Request.
+	newAuthorizer() authorizer
+}
+
+// authorizer authenticates a Request, it may need several round trips.
+type authorizer interface {
+	// authorize sets the credentials to the http.Request before it is sent.
+	authorize(req *http.Request) error
+	// challenge handles the 401 response, returns true if the Request
+	// should be sent again.
+	challenge(resp *Response) (bool, error)
+}
+
+// sendWithAuth sends the Request, and handles the authentication challenges.
+func sendWithAuth(session *Session, req *Request) (*Response, error) {
+	if req.Auth == nil {
+		return send(session, req, nil)
+	}
+	authz := req.Auth.newAuthorizer()
+	for round := 1; ; round++ {
+		resp, err := send(session, req, authz)
+		if err != nil {
+			return nil, err
+		}
+		// Never answer the challenge of another host.
+		if resp.StatusCode != http.StatusUnauthorized || round >= maxAuthRounds || !sameHost(resp.URL, req.URL) {
+			return resp, nil
+		}
+		retry, err := authz.challenge(resp)
+		if err != nil {
+			return nil, WrapErr(err, "authentication failed")
+		}
+		if !retry {
+			return resp, nil
+		}
+	}
+}
+
+// sameHost check whether the two urls have the same host and port.
+func sameHost(url1, url2 string) bool {
+	u1, err := url.Parse(url1)
+	if err != nil {
+		return false
+	}
+	u2, err := url.Parse(url2)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u1.Host, u2.Host)
+}
+
+// BasicAuth is the HTTP Basic authentication, one of the Request Options.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// RequestOption interface method, bind request option to request.
+func (options *BasicAuth) bindRequest(request *Request) error {
+	request.Auth = options
+	return nil
+}
+
+func (options *BasicAuth) newAuthorizer() authorizer {
+	return options
+}
+
+func (options *BasicAuth) authorize(req *http.Request) error {
+	req.SetBasicAuth(options.Username, options.Password)
+	return nil
+}
+
+func (options *BasicAuth) challenge(resp *Response) (bool, error) {
+	return false, nil
+}
+
+// DigestAuth is the HTTP Digest authentication (RFC 7616), one of the
+// Request Options. It supports MD5, SHA-256 and their -sess algorithms,
+// qop auth and auth-int.
+//
+// The challenge of the server is cached, so the next requests to the same
+// host are authenticated without a 401 round trip, and the nonce count
+// increases every time. Please use NewDigestAuth to new it.
+type DigestAuth struct {
+	Username string
+	Password string
+
+	mu         sync.Mutex
+	challenges map[string]*digestChallenge // cached challenges by host
+}
+
+// NewDigestAuth new a DigestAuth.
+func NewDigestAuth(username, password string) *DigestAuth {
+	return &DigestAuth{Username: username, Password: password}
+}
+
+// RequestOption interface method, bind request option to request.
+func (options *DigestAuth) bindRequest(request *Request) error {
+	request.Auth = options
+	return nil
+}
+
+func (options *DigestAuth) newAuthorizer() authorizer {
+	return &digestAuthorizer{auth: options}
+}
+
+// digestChallenge is the parsed WWW-Authenticate header of Digest.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	stale     bool
+	nc        int
+}
+
+// parseDigestChallenge parse the Digest WWW-Authenticate header.
+func parseDigestChallenge(header string) (*digestChallenge, bool) {
+	if !strings.HasPrefix(strings.ToLower(header), "digest ") {
+		return nil, false
+	}
+	params := parseAuthParams(header[len("digest "):])
+	c := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+		stale:     strings.EqualFold(params["stale"], "true"),
+	}
+	if c.algorithm == "" {
+		c.algorithm = "MD5"
+	}
+	if qop, ok := params["qop"]; ok {
+		// prefer auth, use auth-int only if auth is not supported.
+		for _, q := range strings.Split(qop, ",") {
+			q = strings.TrimSpace(q)
+			if q == "auth" || (q == "auth-int" && c.qop == "") {
+				c.qop = q
+			}
+		}
+	}
+	return c, c.nonce != ""
+}
+
+// parseAuthParams parse the auth-params like `realm="x", nonce="y"`.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " ")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value = b.String()
+			if i < len(s) {
+				i++ // skip the closing quote
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// digestAuthorizer authenticates a Request with DigestAuth.
+type digestAuthorizer struct {
+	auth       *DigestAuth
+	challenged int
+}
+
+func (d *digestAuthorizer) authorize(req *http.Request) error {
+	auth := d.auth
+	auth.mu.Lock()
+	c := auth.challenges[req.URL.Host]
+	if c == nil {
+		auth.mu.Unlock()
+		return nil // send without credentials and wait for the challenge.
+	}
+	c.nc++
+	nc := fmt.Sprintf("%08x", c.nc)
+	challenge := *c
+	auth.mu.Unlock()
+
+	cnonce, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	uri := req.URL.RequestURI()
+	var body []byte
+	if challenge.qop == "auth-int" && req.GetBody != nil {
+		reader, err := req.GetBody()
+		if err != nil {
+			return WrapErr(err, "get request body failed")
+		}
+		body, err = ioutil.ReadAll(reader)
+		if err != nil {
+			return WrapErr(err, "read request body failed")
+		}
+	}
+	response, err := digestResponse(&challenge, auth.Username, auth.Password, req.Method, uri, nc, cnonce, body)
+	if err != nil {
+		return err
+	}
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, response="%s"`,
+		auth.Username, challenge.realm, challenge.nonce, uri, challenge.algorithm, response)
+	if challenge.qop != "" {
+		header += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, challenge.qop, nc, cnonce)
+	}
+	if challenge.opaque != "" {
+		header += fmt.Sprintf(`, opaque="%s"`, challenge.opaque)
+	}
+	req.Header.Set("Authorization", header)
+	return nil
+}
+
+func (d *digestAuthorizer) challenge(resp *Response) (bool, error) {
+	for _, header := range resp.Headers.Values("Www-Authenticate") {
+		c, ok := parseDigestChallenge(header)
+		if !ok {
+			continue
+		}
+		// Retry once for a new challenge, or if the nonce is stale.
+		d.challenged++
+		if d.challenged > 1 && !c.stale {
+			return false, nil
+		}
+		u, err := url.Parse(resp.URL)
+		if err != nil {
+			return false, WrapErr(err, "URL error")
+		}
+		d.auth.mu.Lock()
+		defer d.auth.mu.Unlock()
+		if d.auth.challenges == nil {
+			d.auth.challenges = make(map[string]*digestChallenge)
+		}
+		d.auth.challenges[u.Host] = c
+		return true, nil
+	}
+	return false, nil
+}
+
+// digestResponse computes the response of Digest authentication.
+func digestResponse(c *digestChallenge, username, password, method, uri, nc, cnonce string, body []byte) (string, error) {
+	var h func() hash.Hash
+	algorithm := strings.ToUpper(c.algorithm)
+	switch strings.TrimSuffix(algorithm, "-SESS") {
+	case "MD5":
+		h = md5.New
+	case "SHA-256":
+		h = sha256.New
+	default:
+		return "", WrapErrf(ErrAuthChallenge, "unsupported digest algorithm: %s", c.algorithm)
+	}
+	hashHex := func(s string) string {
+		hasher := h()
+		hasher.Write([]byte(s))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	ha1 := hashHex(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = hashHex(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := hashHex(method + ":" + uri)
+	if c.qop == "auth-int" {
+		ha2 = hashHex(method + ":" + uri + ":" + hashHex(string(body)))
+	}
+	if c.qop != "" {
+		return hashHex(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":" + c.qop + ":" + ha2), nil
+	}
+	return hashHex(ha1 + ":" + c.nonce + ":" + ha2), nil
+}
+
+// randomHex returns a random hex string of n bytes.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", WrapErr(err, "generate random bytes failed")
+	}
+	return hex.EncodeToString(b), nil
+}
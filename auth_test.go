@@ -0,0 +1,265 @@
+package direwolf
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(401)
+			return
+		}
+		w.Write([]byte("authorized"))
+	}))
+	defer ts.Close()
+
+	resp, err := Get(ts.URL, &BasicAuth{"user", "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "authorized" {
+		t.Fatal("BasicAuth failed.")
+	}
+	resp, err = Get(ts.URL, &BasicAuth{"user", "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 401 {
+		t.Fatal("BasicAuth failed.")
+	}
+
+	// set as Session default.
+	session := NewSession().With(&BasicAuth{"user", "pass"})
+	resp, err = session.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "authorized" {
+		t.Fatal("BasicAuth Session default failed.")
+	}
+}
+
+func TestAuthRedirectLeak(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Authorization:" + r.Header.Get("Authorization")))
+	}))
+	defer other.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/other":
+			http.Redirect(w, r, other.URL, 302)
+		case "/self":
+			http.Redirect(w, r, "/echo", 302)
+		default:
+			w.Write([]byte("Authorization:" + r.Header.Get("Authorization")))
+		}
+	}))
+	defer ts.Close()
+
+	resp, err := Get(ts.URL+"/other", &BasicAuth{"user", "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "Authorization:" {
+		t.Fatal("Auth leaked to other host: ", resp.Text())
+	}
+	resp, err = Get(ts.URL+"/self", &BasicAuth{"user", "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() == "Authorization:" {
+		t.Fatal("Auth should be kept on same host redirect.")
+	}
+}
+
+func TestDigestResponse(t *testing.T) {
+	// test vectors from RFC 7616 section 3.9.1
+	c := &digestChallenge{
+		realm: "http-auth@example.org",
+		nonce: "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+		qop:   "auth",
+	}
+	cnonce := "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+	expected := map[string]string{
+		"MD5":     "8ca523f5e9506fed4657c9700eebdbec",
+		"SHA-256": "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+	}
+	for algorithm, response := range expected {
+		c.algorithm = algorithm
+		result, err := digestResponse(c, "Mufasa", "Circle of Life", "GET", "/dir/index.html", "00000001", cnonce, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != response {
+			t.Fatal("digestResponse failed: ", algorithm, result)
+		}
+	}
+
+	params := parseAuthParams(`realm="a \"b\"", qop="auth,auth-int", algorithm=SHA-256, stale=TRUE`)
+	if params["realm"] != `a "b"` || params["qop"] != "auth,auth-int" || params["algorithm"] != "SHA-256" || params["stale"] != "TRUE" {
+		t.Fatal("parseAuthParams failed: ", params)
+	}
+}
+
+func newTestDigestServer(qop string) (*httptest.Server, *int) {
+	var mu sync.Mutex
+	requests := 0
+	lastNC := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		challenge := `Digest realm="direwolf", nonce="abcdef", opaque="xyz", algorithm=MD5, qop="` + qop + `"`
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Digest ") {
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(401)
+			return
+		}
+		params := parseAuthParams(header[len("Digest "):])
+		body := make([]byte, r.ContentLength)
+		r.Body.Read(body)
+		c := &digestChallenge{realm: "direwolf", nonce: "abcdef", algorithm: "MD5", qop: params["qop"]}
+		response, _ := digestResponse(c, "user", "pass", r.Method, params["uri"], params["nc"], params["cnonce"], body)
+		var nc int
+		ncBytes, _ := hex.DecodeString(params["nc"])
+		for _, b := range ncBytes {
+			nc = nc<<8 | int(b)
+		}
+		if params["response"] != response || params["opaque"] != "xyz" || nc != lastNC+1 {
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(401)
+			return
+		}
+		lastNC = nc
+		w.Write([]byte("authorized " + params["qop"]))
+	}))
+	return ts, &requests
+}
+
+func TestDigestAuth(t *testing.T) {
+	ts, requests := newTestDigestServer("auth,auth-int")
+	defer ts.Close()
+
+	auth := NewDigestAuth("user", "pass")
+	resp, err := Get(ts.URL+"/dir/index.html?a=1", auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "authorized auth" || *requests != 2 {
+		t.Fatal("DigestAuth failed: ", resp.Text())
+	}
+
+	// the challenge is cached, and nonce count increases.
+	resp, err = Post(ts.URL, Body("key=value"), auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "authorized auth" || *requests != 3 {
+		t.Fatal("DigestAuth nonce count failed: ", resp.Text())
+	}
+
+	resp, err = Get(ts.URL, NewDigestAuth("user", "wrong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 401 || *requests != 5 {
+		t.Fatal("DigestAuth wrong password failed: ", *requests)
+	}
+}
+
+func TestDigestAuthInt(t *testing.T) {
+	ts, _ := newTestDigestServer("auth-int")
+	defer ts.Close()
+
+	resp, err := Post(ts.URL, Body("key=value"), NewDigestAuth("user", "pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "authorized auth-int" {
+		t.Fatal("DigestAuth auth-int failed: ", resp.Text())
+	}
+}
+
+func TestNTLMv2Response(t *testing.T) {
+	// test vectors from MS-NLMP section 4.2.4
+	auth := &NTLMAuth{Domain: "Domain", Username: "User", Password: "Password"}
+	serverChallenge, _ := hex.DecodeString("0123456789abcdef")
+	clientChallenge, _ := hex.DecodeString("aaaaaaaaaaaaaaaa")
+	targetInfo, _ := hex.DecodeString("02000c0044006f006d00610069006e00" + "01000c00530065007200760065007200" + "00000000")
+	ntResponse, lmResponse := ntlmV2Response(auth, serverChallenge, clientChallenge, make([]byte, 8), targetInfo)
+	if hex.EncodeToString(ntResponse[:16]) != "68cd0ab851e51c96aabc927bebef6a1c" {
+		t.Fatal("NTLMv2 response failed: ", hex.EncodeToString(ntResponse[:16]))
+	}
+	if hex.EncodeToString(lmResponse) != "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa" {
+		t.Fatal("LMv2 response failed: ", hex.EncodeToString(lmResponse))
+	}
+}
+
+func TestNTLMAuth(t *testing.T) {
+	serverChallenge := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	targetInfo := []byte{0, 0, 0, 0} // MsvAvEOL
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		message, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "NTLM "))
+		if len(message) < 12 {
+			w.Header().Set("WWW-Authenticate", "NTLM")
+			w.WriteHeader(401)
+			return
+		}
+		switch binary.LittleEndian.Uint32(message[8:]) {
+		case 1: // negotiate, send the challenge
+			challenge := make([]byte, 48)
+			copy(challenge, ntlmSignature)
+			binary.LittleEndian.PutUint32(challenge[8:], 2)
+			binary.LittleEndian.PutUint32(challenge[20:], ntlmNegotiateFlags)
+			copy(challenge[24:], serverChallenge)
+			binary.LittleEndian.PutUint16(challenge[40:], uint16(len(targetInfo)))
+			binary.LittleEndian.PutUint16(challenge[42:], uint16(len(targetInfo)))
+			binary.LittleEndian.PutUint32(challenge[44:], 48)
+			challenge = append(challenge, targetInfo...)
+			w.Header().Set("WWW-Authenticate", "NTLM "+base64.StdEncoding.EncodeToString(challenge))
+			w.WriteHeader(401)
+		case 3: // authenticate, verify the NTLMv2 response
+			field := func(i int) []byte {
+				length := binary.LittleEndian.Uint16(message[12+i*8:])
+				offset := binary.LittleEndian.Uint32(message[16+i*8:])
+				return message[offset : offset+uint32(length)]
+			}
+			ntResponse := field(1)
+			clientChallenge := ntResponse[32:40]
+			auth := &NTLMAuth{Domain: "DOMAIN", Username: "user", Password: "pass"}
+			expected, _ := ntlmV2Response(auth, serverChallenge, clientChallenge, ntResponse[24:32], targetInfo)
+			if string(expected) != string(ntResponse) || string(field(3)) != string(utf16LE("user")) {
+				w.WriteHeader(401)
+				return
+			}
+			w.Write([]byte("authorized"))
+		}
+	}))
+	defer ts.Close()
+
+	resp, err := Get(ts.URL, &NTLMAuth{Domain: "DOMAIN", Username: "user", Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "authorized" {
+		t.Fatal("NTLMAuth failed: ", resp.StatusCode)
+	}
+	resp, err = Get(ts.URL, &NTLMAuth{Domain: "DOMAIN", Username: "user", Password: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 401 {
+		t.Fatal("NTLMAuth wrong password failed.")
+	}
+}
@@ -0,0 +1,59 @@
+package direwolf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultCompressMinSize is the default threshold of request body compression.
+const defaultCompressMinSize = 1024
+
+// shouldCompress check whether a body of the given size need to be compressed.
+func (c *Compress) shouldCompress(size int) bool {
+	if size == 0 {
+		return false
+	}
+	if c.MinSize > 0 {
+		return size >= c.MinSize
+	} else if c.MinSize == 0 {
+		return size >= defaultCompressMinSize
+	}
+	return true
+}
+
+// compressBody compress the data with the given encoding.
+// It just support gzip, deflate, br and zstd now.
+func compressBody(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch strings.ToLower(encoding) {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "deflate":
+		writer = zlib.NewWriter(&buf)
+	case "br":
+		writer = brotli.NewWriter(&buf)
+	case "zstd":
+		zstdWriter, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, WrapErr(err, "new zstd writer failed")
+		}
+		writer = zstdWriter
+	default:
+		return nil, WrapErrf(ErrCompressEncoding, "unsupported encoding: %s", encoding)
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, WrapErr(err, "compress request body failed")
+	}
+	if err := writer.Close(); err != nil {
+		return nil, WrapErr(err, "compress request body failed")
+	}
+	return buf.Bytes(), nil
+}
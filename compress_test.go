@@ -0,0 +1,112 @@
+package direwolf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+func newTestCompressServer() *httptest.Server {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.POST("/", func(c *gin.Context) {
+		var reader io.Reader
+		var err error
+		switch c.GetHeader("Content-Encoding") {
+		case "gzip":
+			reader, err = gzip.NewReader(c.Request.Body)
+		case "deflate":
+			reader, err = zlib.NewReader(c.Request.Body)
+		case "br":
+			reader = brotli.NewReader(c.Request.Body)
+		case "zstd":
+			reader, err = zstd.NewReader(c.Request.Body)
+		default:
+			reader = c.Request.Body
+		}
+		if err != nil {
+			c.AbortWithStatus(400)
+			return
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			c.AbortWithStatus(400)
+			return
+		}
+		c.String(200, c.GetHeader("Content-Encoding")+":"+string(data))
+	})
+	ts := httptest.NewServer(router)
+	return ts
+}
+
+func TestCompress(t *testing.T) {
+	ts := newTestCompressServer()
+	defer ts.Close()
+
+	data := strings.Repeat("direwolf", 200)
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		compress := &Compress{Encoding: encoding}
+		resp, err := Post(ts.URL, Body(data), compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Text() != encoding+":"+data {
+			t.Fatal("Compress failed: ", encoding)
+		}
+	}
+
+	// body smaller than MinSize should not be compressed.
+	resp, err := Post(ts.URL, Body("key=value"), &Compress{Encoding: "gzip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != ":key=value" {
+		t.Fatal("Compress MinSize failed.")
+	}
+	resp, err = Post(ts.URL, Body("key=value"), &Compress{Encoding: "gzip", MinSize: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "gzip:key=value" {
+		t.Fatal("Compress MinSize failed.")
+	}
+
+	_, err = Post(ts.URL, Body(data), &Compress{Encoding: "lzma"})
+	if !errors.Is(err, ErrCompressEncoding) {
+		t.Fatal("Compress unsupported encoding failed.")
+	}
+}
+
+func TestSessionCompress(t *testing.T) {
+	ts := newTestCompressServer()
+	defer ts.Close()
+
+	session := NewSession()
+	session.Compress = &Compress{Encoding: "gzip", MinSize: -1}
+	resp, err := session.Post(ts.URL, NewPostForm("key", "value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "gzip:key=value" {
+		t.Fatal("Session.Compress failed.")
+	}
+
+	// Request has higher priority.
+	resp, err = session.Post(ts.URL, Body("key=value"), &Compress{Encoding: "deflate", MinSize: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp.Content, []byte("deflate:key=value")) {
+		t.Fatal("Session.Compress failed.")
+	}
+}
@@ -0,0 +1,550 @@
+package direwolf
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ToCurl returns the equivalent curl command of the Request, including the
+// headers, cookies, body, proxy, timeout, redirect and auth settings. Only the
+// settings of the Request are used, the Session defaults are not included.
+// The body is not compressed even if Compress is set.
+func (req *Request) ToCurl() string {
+	args := []string{"curl"}
+	method := req.Method
+	if method == "" {
+		method = "GET"
+	}
+	hasBody := req.PostForm != nil || req.Body != nil || req.JsonBody != nil
+	if method == "HEAD" && !hasBody {
+		args = append(args, "-I")
+	} else if (hasBody && method != "POST") || (!hasBody && method != "GET") {
+		// curl sends POST if there is a body, so the other methods are
+		// always set, GET included.
+		args = append(args, "-X", method)
+	}
+	args = append(args, shellQuote(req.URL))
+
+	headers := req.Headers.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	var body []byte
+	if req.PostForm != nil {
+		headers.Set("Content-Type", "application/x-www-form-urlencoded")
+		body = []byte(req.PostForm.URLEncode())
+	} else if req.Body != nil {
+		body = req.Body
+	} else if req.JsonBody != nil {
+		headers.Set("Content-Type", "application/json")
+		body = req.JsonBody
+	}
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range headers[key] {
+			args = append(args, "-H", shellQuote(key+": "+value))
+		}
+	}
+
+	if len(req.Cookies) > 0 {
+		cookies := make([]string, len(req.Cookies))
+		for i, cookie := range req.Cookies {
+			cookies[i] = cookie.Name + "=" + cookie.Value
+		}
+		args = append(args, "-b", shellQuote(strings.Join(cookies, "; ")))
+	}
+	if hasBody {
+		args = append(args, "--data-binary", shellQuote(string(body)))
+	}
+
+	switch auth := req.Auth.(type) {
+	case *BasicAuth:
+		args = append(args, "-u", shellQuote(auth.Username+":"+auth.Password))
+	case *DigestAuth:
+		args = append(args, "--digest", "-u", shellQuote(auth.Username+":"+auth.Password))
+	case *NTLMAuth:
+		username := auth.Username
+		if auth.Domain != "" {
+			username = auth.Domain + `\` + username
+		}
+		args = append(args, "--ntlm", "-u", shellQuote(username+":"+auth.Password))
+	}
+
+	if req.Proxy != nil {
+		proxy := req.Proxy.HTTP
+		if strings.HasPrefix(strings.ToLower(req.URL), "https:") {
+			proxy = req.Proxy.HTTPS
+		}
+		if proxy != "" {
+			args = append(args, "-x", shellQuote(proxy))
+		}
+	}
+
+	if req.Timeouts != nil && req.Timeouts.Total > 0 {
+		args = append(args, "-m", formatSeconds(req.Timeouts.Total))
+	} else if req.Timeout > 0 {
+		args = append(args, "-m", strconv.Itoa(req.Timeout))
+	}
+	if req.Timeouts != nil && req.Timeouts.Connect > 0 {
+		args = append(args, "--connect-timeout", formatSeconds(req.Timeouts.Connect))
+	}
+
+	// direwolf follows 10 redirects by default.
+	if req.RedirectNum == 0 {
+		args = append(args, "-L", "--max-redirs", "10")
+	} else if req.RedirectNum > 0 {
+		args = append(args, "-L", "--max-redirs", strconv.Itoa(req.RedirectNum))
+	}
+	return strings.Join(args, " ")
+}
+
+// ParseCurl parses the curl command into a Request, such as the command copied
+// from the browser devtools. It supports the common flags:
+//
+//	-X/--request, -H/--header, -d/--data, --data-raw, --data-binary,
+//	--data-urlencode, --json, -b/--cookie, -u/--user, --digest, --ntlm,
+//	-x/--proxy, -m/--max-time, --connect-timeout, -L/--location,
+//	--max-redirs, -A/--user-agent, -e/--referer, -I/--head, -G/--get, --url.
+//
+// Flags which do not change the request, like --compressed and -s, are
+// ignored. Redirects are followed like other direwolf requests even if -L
+// is not set.
+func ParseCurl(command string) (*Request, error) {
+	words, err := splitShellWords(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 || words[0] != "curl" {
+		return nil, WrapErr(ErrCurl, "command must start with curl")
+	}
+
+	req := &Request{Headers: http.Header{}}
+	var rawURL, method, username, password, authType string
+	var data []string
+	var head, get, hasUser bool
+
+	args := expandCurlFlags(words[1:])
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			rawURL = arg
+			continue
+		}
+		if curlIgnoredFlags[arg] {
+			continue
+		}
+		if arg == "-L" || arg == "--location" {
+			continue
+		}
+		switch arg {
+		case "-I", "--head":
+			head = true
+			continue
+		case "-G", "--get":
+			get = true
+			continue
+		case "--digest":
+			authType = "digest"
+			continue
+		case "--ntlm":
+			authType = "ntlm"
+			continue
+		case "--basic":
+			authType = ""
+			continue
+		}
+
+		// flags with value.
+		if i+1 >= len(args) {
+			return nil, WrapErrf(ErrCurl, "missing value of %s", arg)
+		}
+		i++
+		value := args[i]
+		switch arg {
+		case "--url":
+			rawURL = value
+		case "-X", "--request":
+			method = strings.ToUpper(value)
+		case "-H", "--header":
+			kv := strings.SplitN(value, ":", 2)
+			if len(kv) != 2 {
+				return nil, WrapErrf(ErrCurl, "invalid header: %s", value)
+			}
+			req.Headers.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		case "-A", "--user-agent":
+			req.Headers.Set("User-Agent", value)
+		case "-e", "--referer":
+			req.Headers.Set("Referer", value)
+		case "-d", "--data", "--data-ascii", "--data-binary":
+			if strings.HasPrefix(value, "@") {
+				return nil, WrapErrf(ErrCurl, "reading data from file is not supported: %s", value)
+			}
+			if arg != "--data-binary" {
+				value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+			}
+			data = append(data, value)
+		case "--data-raw":
+			data = append(data, value)
+		case "--data-urlencode":
+			data = append(data, curlURLEncode(value))
+		case "--json":
+			data = append(data, value)
+			if req.Headers.Get("Content-Type") == "" {
+				req.Headers.Set("Content-Type", "application/json")
+			}
+			if req.Headers.Get("Accept") == "" {
+				req.Headers.Set("Accept", "application/json")
+			}
+		case "-b", "--cookie":
+			if !strings.Contains(value, "=") {
+				return nil, WrapErrf(ErrCurl, "reading cookies from file is not supported: %s", value)
+			}
+			for _, pair := range strings.Split(value, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 {
+					req.Cookies = append(req.Cookies, &http.Cookie{Name: kv[0], Value: kv[1]})
+				}
+			}
+		case "-u", "--user":
+			hasUser = true
+			kv := strings.SplitN(value, ":", 2)
+			username = kv[0]
+			if len(kv) == 2 {
+				password = kv[1]
+			}
+		case "-x", "--proxy":
+			if !strings.Contains(value, "://") {
+				value = "http://" + value
+			}
+			req.Proxy = &Proxy{HTTP: value, HTTPS: value}
+		case "-m", "--max-time":
+			d, err := parseSeconds(value)
+			if err != nil {
+				return nil, WrapErrf(err, "invalid %s", arg)
+			}
+			if d%time.Second == 0 {
+				req.Timeout = int(d / time.Second)
+			} else {
+				if req.Timeouts == nil {
+					req.Timeouts = &Timeouts{}
+				}
+				req.Timeouts.Total = d
+			}
+		case "--connect-timeout":
+			d, err := parseSeconds(value)
+			if err != nil {
+				return nil, WrapErrf(err, "invalid %s", arg)
+			}
+			if req.Timeouts == nil {
+				req.Timeouts = &Timeouts{}
+			}
+			req.Timeouts.Connect = d
+		case "--max-redirs":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, WrapErrf(ErrCurl, "invalid %s: %s", arg, value)
+			}
+			if n == 0 {
+				req.RedirectNum = -1
+			} else if n > 0 {
+				req.RedirectNum = n
+			}
+		case "-o", "--output", "-w", "--write-out", "--retry", "--cacert", "--cert", "--key":
+		default:
+			return nil, WrapErrf(ErrCurl, "unsupported flag: %s", arg)
+		}
+	}
+
+	if rawURL == "" {
+		return nil, WrapErr(ErrCurl, "missing url")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	if _, err := url.Parse(rawURL); err != nil {
+		return nil, WrapErr(err, "URL error")
+	}
+	req.URL = rawURL
+
+	if len(data) > 0 {
+		body := strings.Join(data, "&")
+		if get {
+			if strings.Contains(req.URL, "?") {
+				req.URL += "&" + body
+			} else {
+				req.URL += "?" + body
+			}
+		} else {
+			req.Body = []byte(body)
+			if req.Headers.Get("Content-Type") == "" {
+				req.Headers.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+		}
+	}
+
+	switch {
+	case method != "":
+		req.Method = method
+	case head:
+		req.Method = "HEAD"
+	case req.Body != nil:
+		req.Method = "POST"
+	default:
+		req.Method = "GET"
+	}
+
+	if hasUser {
+		switch authType {
+		case "digest":
+			req.Auth = NewDigestAuth(username, password)
+		case "ntlm":
+			auth := &NTLMAuth{Username: username, Password: password}
+			if kv := strings.SplitN(username, `\`, 2); len(kv) == 2 {
+				auth.Domain, auth.Username = kv[0], kv[1]
+			}
+			req.Auth = auth
+		default:
+			req.Auth = &BasicAuth{Username: username, Password: password}
+		}
+	}
+	if len(req.Headers) == 0 {
+		req.Headers = nil
+	}
+	return req, nil
+}
+
+// curlIgnoredFlags are the flags without value which do not change the request.
+var curlIgnoredFlags = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true,
+	"-v": true, "--verbose": true, "-i": true, "--include": true,
+	"-k": true, "--insecure": true, "--compressed": true, "-f": true,
+	"--fail": true, "-g": true, "--globoff": true, "-N": true,
+	"--no-buffer": true, "--http1.1": true, "--http2": true,
+}
+
+// curlShortValueFlags are the short flags with value.
+const curlShortValueFlags = "XHdbuxmAeowF"
+
+// expandCurlFlags expands the combined short flags, such as "-sSL" to "-s",
+// "-S", "-L", and "-XPOST" to "-X", "POST". The values of flags are kept.
+func expandCurlFlags(args []string) []string {
+	var expanded []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || arg[0] != '-' {
+			expanded = append(expanded, arg)
+			continue
+		}
+		if arg[1] == '-' || len(arg) == 2 {
+			expanded = append(expanded, arg)
+			if curlHasValue(arg) && i+1 < len(args) {
+				i++
+				expanded = append(expanded, args[i])
+			}
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			flag := "-" + string(arg[j])
+			expanded = append(expanded, flag)
+			if curlHasValue(flag) {
+				if j+1 < len(arg) {
+					expanded = append(expanded, arg[j+1:])
+				} else if i+1 < len(args) {
+					i++
+					expanded = append(expanded, args[i])
+				}
+				break
+			}
+		}
+	}
+	return expanded
+}
+
+// curlHasValue reports whether the flag has a value.
+func curlHasValue(flag string) bool {
+	if strings.HasPrefix(flag, "--") {
+		return !curlIgnoredFlags[flag] && !curlBoolFlags[flag]
+	}
+	return len(flag) == 2 && strings.IndexByte(curlShortValueFlags, flag[1]) >= 0
+}
+
+// curlBoolFlags are the long flags without value which are handled.
+var curlBoolFlags = map[string]bool{
+	"--location": true, "--head": true, "--get": true,
+	"--digest": true, "--ntlm": true, "--basic": true,
+}
+
+// curlURLEncode encodes the value of --data-urlencode, "name=content" is
+// encoded to name and the encoded content.
+func curlURLEncode(value string) string {
+	if i := strings.IndexByte(value, '='); i >= 0 {
+		if i == 0 {
+			return url.QueryEscape(value[1:])
+		}
+		return value[:i] + "=" + url.QueryEscape(value[i+1:])
+	}
+	return url.QueryEscape(value)
+}
+
+// shellQuote quotes the string by single quotes if needed.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_./:=@,+%", c)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// splitShellWords splits the command into words like POSIX shell. It supports
+// single quotes, double quotes, $'...' quotes, backslash escapes and line
+// continuations.
+func splitShellWords(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			inWord = true
+			if i+1 < len(command) {
+				i++
+				if command[i] == '\n' { // line continuation
+					inWord = word.Len() > 0
+					continue
+				}
+				if command[i] == '\r' && i+1 < len(command) && command[i+1] == '\n' {
+					i++
+					inWord = word.Len() > 0
+					continue
+				}
+				word.WriteByte(command[i])
+			}
+		case c == '\'':
+			inWord = true
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, WrapErr(ErrCurl, "unterminated single quote")
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+		case c == '$' && i+1 < len(command) && command[i+1] == '\'':
+			inWord = true
+			n, err := ansiCQuote(command[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+		case c == '"':
+			inWord = true
+			closed := false
+			for i++; i < len(command); i++ {
+				if command[i] == '"' {
+					closed = true
+					break
+				}
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("\"\\$`\n", command[i+1]) >= 0 {
+					i++
+					if command[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(command[i])
+			}
+			if !closed {
+				return nil, WrapErr(ErrCurl, "unterminated double quote")
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// ansiCQuote decodes the content of $'...' quote into word, it returns the
+// length of the content including the closing quote.
+func ansiCQuote(s string, word *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i, nil
+		}
+		if c != '\\' || i+1 >= len(s) {
+			word.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			word.WriteByte('\n')
+		case 't':
+			word.WriteByte('\t')
+		case 'r':
+			word.WriteByte('\r')
+		case '0':
+			word.WriteByte(0)
+		case 'x':
+			if i+2 < len(s) {
+				if b, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+					word.WriteByte(byte(b))
+					i += 2
+					continue
+				}
+			}
+			word.WriteString(`\x`)
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					word.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			word.WriteString(`\u`)
+		default: // \\, \', \" and the others
+			word.WriteByte(s[i])
+		}
+	}
+	return 0, WrapErr(ErrCurl, "unterminated $' quote")
+}
+
+// formatSeconds formats the duration as seconds for curl.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// parseSeconds parses the seconds of curl, which can be fractional.
+func parseSeconds(s string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil || seconds < 0 {
+		return 0, WrapErrf(ErrCurl, "invalid seconds: %s", s)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
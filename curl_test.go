@@ -0,0 +1,144 @@
+package direwolf
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestToCurl(t *testing.T) {
+	req, _ := NewRequest("POST", "http://example.com/api",
+		NewParams("q", "direwolf"),
+		NewHeaders("X-Token", "it's"),
+		NewCookies("a", "1", "b", "2"),
+		NewPostForm("name", "wolf"),
+		&Proxy{HTTP: "http://127.0.0.1:8080"},
+		Timeout(10),
+		&BasicAuth{Username: "user", Password: "pass"},
+	)
+	expected := `curl 'http://example.com/api?q=direwolf' -H 'Content-Type: application/x-www-form-urlencoded' ` +
+		`-H 'X-Token: it'\''s' -b 'a=1; b=2' --data-binary name=wolf -u user:pass -x http://127.0.0.1:8080 ` +
+		`-m 10 -L --max-redirs 10`
+	if req.ToCurl() != expected {
+		t.Fatal("Request.ToCurl() failed: ", req.ToCurl())
+	}
+
+	req = &Request{
+		Method:      "PUT",
+		URL:         "https://example.com",
+		JsonBody:    []byte(`{"a": 1}`),
+		RedirectNum: -1,
+		Timeouts:    &Timeouts{Total: 1500 * time.Millisecond, Connect: time.Second},
+	}
+	expected = `curl -X PUT https://example.com -H 'Content-Type: application/json' --data-binary '{"a": 1}' ` +
+		`-m 1.5 --connect-timeout 1`
+	if req.ToCurl() != expected {
+		t.Fatal("Request.ToCurl() failed: ", req.ToCurl())
+	}
+
+	req = &Request{Method: "GET", URL: "https://example.com", Body: []byte("a=1"), RedirectNum: -1}
+	expected = `curl -X GET https://example.com --data-binary a=1`
+	if req.ToCurl() != expected {
+		t.Fatal("Request.ToCurl() failed: ", req.ToCurl())
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	// copied from browser devtools.
+	command := `curl 'https://example.com/api?id=1' \
+  -H 'accept: application/json' \
+  -H 'user-agent: Mozilla/5.0' \
+  -b 'session=abc; theme=dark' \
+  --data-raw $'{"name":"it\'s"}' \
+  --compressed`
+	req, err := ParseCurl(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "POST" || req.URL != "https://example.com/api?id=1" {
+		t.Fatal("ParseCurl failed: ", req.Method, req.URL)
+	}
+	if req.Headers.Get("Accept") != "application/json" || req.Headers.Get("User-Agent") != "Mozilla/5.0" {
+		t.Fatal("ParseCurl headers failed: ", req.Headers)
+	}
+	if len(req.Cookies) != 2 || req.Cookies[1].Name != "theme" || req.Cookies[1].Value != "dark" {
+		t.Fatal("ParseCurl cookies failed.")
+	}
+	if string(req.Body) != `{"name":"it's"}` {
+		t.Fatal("ParseCurl body failed: ", string(req.Body))
+	}
+
+	req, err = ParseCurl(`curl -sSLXPUT example.com -d a=1 -d "b=2" --data-urlencode 'c=x y' -u "user:p\"w" --digest -m 2.5 --max-redirs 3 -x 127.0.0.1:8080`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "PUT" || req.URL != "http://example.com" || string(req.Body) != "a=1&b=2&c=x+y" {
+		t.Fatal("ParseCurl failed: ", req.Method, req.URL, string(req.Body))
+	}
+	if req.Headers.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		t.Fatal("ParseCurl Content-Type failed.")
+	}
+	auth, ok := req.Auth.(*DigestAuth)
+	if !ok || auth.Username != "user" || auth.Password != `p"w` {
+		t.Fatal("ParseCurl auth failed.")
+	}
+	if req.Timeouts.Total != 2500*time.Millisecond || req.RedirectNum != 3 || req.Proxy.HTTPS != "http://127.0.0.1:8080" {
+		t.Fatal("ParseCurl options failed.")
+	}
+
+	req, err = ParseCurl(`curl -G http://example.com/search -d q=wolf -I`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "HEAD" || req.URL != "http://example.com/search?q=wolf" || req.Body != nil {
+		t.Fatal("ParseCurl -G failed: ", req.Method, req.URL)
+	}
+
+	for _, command := range []string{"wget http://example.com", "curl", "curl -H", "curl 'http://a", "curl -F a=b http://a", "curl -d @file http://a"} {
+		if _, err := ParseCurl(command); !errors.Is(err, ErrCurl) {
+			t.Fatal("ParseCurl invalid command failed: ", command)
+		}
+	}
+}
+
+func TestCurlRoundTrip(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		cookie, _ := r.Cookie("a")
+		username, password, _ := r.BasicAuth()
+		fmt.Fprintf(w, "%s %s %s %s %s %s:%s", r.Method, r.URL.RequestURI(), r.Header.Get("X-Token"),
+			cookie.Value, body, username, password)
+	}))
+	defer ts.Close()
+
+	req, _ := NewRequest("PATCH", ts.URL+"/path", NewParams("q", "1"), NewHeaders("X-Token", `a 'b' "c"`),
+		NewCookies("a", "1"), Body("hello\nworld"), &BasicAuth{Username: "user", Password: "pass"})
+	parsed, err := ParseCurl(req.ToCurl())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := NewSession().Send(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != `PATCH /path?q=1 a 'b' "c" 1 hello`+"\n"+`world user:pass` {
+		t.Fatal("curl round trip failed: ", resp.Text())
+	}
+
+	req, _ = NewRequest("GET", ts.URL+"/path", NewCookies("a", "2"), Body("query"))
+	parsed, err = ParseCurl(req.ToCurl())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = NewSession().Send(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != `GET /path  2 query :` {
+		t.Fatal("curl round trip failed: ", resp.Text())
+	}
+}
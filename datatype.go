@@ -79,6 +79,88 @@ func (options *Proxy) bindRequest(request *Request) error {
 	return nil
 }
 
+// Compress is the way to compress the request body, one of the Request Options.
+// Encoding can be gzip, deflate, br or zstd, it will be set to Content-Encoding.
+// If MinSize > 0, body smaller than MinSize will not be compressed.
+// If MinSize < 0, it means always compress the body.
+// If MinSize = 0, it means keep default 1024 bytes threshold.
+type Compress struct {
+	Encoding string
+	MinSize  int
+}
+
+// RequestOption interface method, bind request option to request.
+func (options *Compress) bindRequest(request *Request) error {
+	request.Compress = options
+	return nil
+}
+
+// MergeMode is the way to merge a Request value with the Session value of
+// the same key.
+type MergeMode int
+
+const (
+	// MergeReplace means the Request value replaces the Session value.
+	// It is the default mode.
+	MergeReplace MergeMode = iota
+	// MergeAppend means the Request value is appended to the Session value.
+	MergeAppend
+	// MergeDelete means the Session value is removed for this request.
+	MergeDelete
+)
+
+// Merge specifies how to merge the Headers, Cookies and Params of Request
+// with the Session ones, one of the Request Options. The keys not in
+// the map use MergeReplace. Like this:
+//
+//	merge := &dw.Merge{
+//		Headers: map[string]dw.MergeMode{"Accept": dw.MergeAppend},
+//		Params:  map[string]dw.MergeMode{"token": dw.MergeDelete},
+//	}
+//
+// Session cookies here are the default cookies set by Session.With, the
+// cookies in cookie jar are not affected.
+type Merge struct {
+	Headers map[string]MergeMode
+	Cookies map[string]MergeMode
+	Params  map[string]MergeMode
+}
+
+// RequestOption interface method, bind request option to request.
+func (options *Merge) bindRequest(request *Request) error {
+	request.Merge = options
+	return nil
+}
+
+// headerMode returns the MergeMode of the header key.
+func (options *Merge) headerMode(key string) MergeMode {
+	if options == nil {
+		return MergeReplace
+	}
+	for k, mode := range options.Headers {
+		if http.CanonicalHeaderKey(k) == http.CanonicalHeaderKey(key) {
+			return mode
+		}
+	}
+	return MergeReplace
+}
+
+// cookieMode returns the MergeMode of the cookie name.
+func (options *Merge) cookieMode(name string) MergeMode {
+	if options == nil {
+		return MergeReplace
+	}
+	return options.Cookies[name]
+}
+
+// paramMode returns the MergeMode of the param key.
+func (options *Merge) paramMode(key string) MergeMode {
+	if options == nil {
+		return MergeReplace
+	}
+	return options.Params[key]
+}
+
 // strSliceMap type is map[string][]string, used for Params, PostForm.
 type strSliceMap struct {
 	data map[string][]string
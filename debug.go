@@ -0,0 +1,130 @@
+package direwolf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+)
+
+// defaultDumpBodyLimit is the default max length of the dumped body.
+const defaultDumpBodyLimit = 1024
+
+// DebugDump writes the wire format of the requests and responses to Writer,
+// one of the SessionOptions. Every request is dumped, including redirects and
+// retries. It can be set to an existing Session by WrapTransport. Like this:
+//
+//	session.WrapTransport((&dw.DebugDump{Writer: os.Stderr}).Wrap)
+//
+// The requests are dumped like httputil.DumpRequestOut, and the responses
+// like httputil.DumpResponse. The response body is read before it is
+// returned, so the streaming and idle timeout do not work in debug mode.
+type DebugDump struct {
+	Writer io.Writer
+
+	// BodyLimit is the max length of the dumped body, the rest is truncated.
+	// Default is 1024 bytes, negative means no limit.
+	BodyLimit int
+
+	// MaskHeaders are the sensitive headers whose values are masked.
+	// Default is Authorization, Proxy-Authorization, Cookie, Set-Cookie and
+	// X-Api-Key, set it to an empty slice to dump all of them.
+	MaskHeaders []string
+
+	mu    sync.Mutex
+	count int
+}
+
+// Wrap returns a http.RoundTripper which dumps the requests sent by next.
+func (dump *DebugDump) Wrap(next http.RoundTripper) http.RoundTripper {
+	return &debugTransport{dump: dump, next: next}
+}
+
+// debugTransport dumps the requests and responses.
+type debugTransport struct {
+	dump *DebugDump
+	next http.RoundTripper
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.dump.mu.Lock()
+	t.dump.count++
+	n := t.dump.count
+	t.dump.mu.Unlock()
+
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	// DumpRequestOut sends the request by a fake transport, so the trace of
+	// the request context must not be used.
+	masked := req.Clone(context.Background())
+	masked.Header = t.dump.mask(req.Header)
+	if body != nil { // keep the Content-Length of the dumped request.
+		masked.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	head, err := httputil.DumpRequestOut(masked, false)
+	if err != nil {
+		return nil, WrapErr(err, "dump request failed")
+	}
+	t.dump.write(fmt.Sprintf("=== request %d ===\n", n), head, body)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.dump.write(fmt.Sprintf("=== error %d (%s) ===\n%s\n\n", n, time.Since(start), err), nil, nil)
+		return nil, err
+	}
+
+	content, readErr := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(content), errReader{readErr}))
+	header := resp.Header
+	resp.Header = t.dump.mask(header)
+	head, err = httputil.DumpResponse(resp, false)
+	resp.Header = header
+	if err != nil {
+		return nil, WrapErr(err, "dump response failed")
+	}
+	t.dump.write(fmt.Sprintf("=== response %d (%s) ===\n", n, time.Since(start)), head, content)
+	return resp, nil
+}
+
+// write writes the dump at once, so that the dumps of concurrent requests
+// are not mixed.
+func (dump *DebugDump) write(title string, head, body []byte) {
+	var b bytes.Buffer
+	b.WriteString(title)
+	b.Write(head)
+	if len(body) > 0 {
+		limit := dump.BodyLimit
+		if limit == 0 {
+			limit = defaultDumpBodyLimit
+		}
+		if limit > 0 && len(body) > limit {
+			b.Write(body[:limit])
+			fmt.Fprintf(&b, "\n... [truncated %d bytes]", len(body)-limit)
+		} else {
+			b.Write(body)
+		}
+		b.WriteString("\n\n")
+	}
+
+	dump.mu.Lock()
+	defer dump.mu.Unlock()
+	dump.Writer.Write(b.Bytes())
+}
+
+// mask returns a copy of the header, the sensitive headers are masked.
+func (dump *DebugDump) mask(header http.Header) http.Header {
+	maskHeaders := dump.MaskHeaders
+	if maskHeaders == nil {
+		maskHeaders = defaultRedactHeaders
+	}
+	return redactHeaders(header, maskHeaders)
+}
@@ -0,0 +1,75 @@
+package direwolf
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugDump(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "token", Value: "secret-cookie"})
+			http.Redirect(w, r, "/home", 302)
+			return
+		}
+		w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	options := DefaultSessionOptions()
+	options.Debug = &DebugDump{Writer: &out, BodyLimit: 10}
+	session := NewSession(options)
+	if _, err := session.Post(ts.URL+"/login", Body("user=wolf"), &BasicAuth{Username: "user", Password: "secret-pass"}); err != nil {
+		t.Fatal(err)
+	}
+	dump := out.String()
+	for _, expected := range []string{
+		"=== request 1 ===\nPOST /login HTTP/1.1\r\n",
+		"Content-Length: 9\r\n",
+		"Authorization: [REDACTED]\r\n",
+		"\r\n\r\nuser=wolf\n\n",
+		"=== response 1 (",
+		"HTTP/1.1 302 Found\r\n",
+		"Set-Cookie: [REDACTED]\r\n",
+		"=== request 2 ===\nGET /home HTTP/1.1\r\n",
+		"Cookie: [REDACTED]\r\n",
+		"HTTP/1.1 200 OK\r\n",
+		"\r\n\r\naaaaaaaaaa\n... [truncated 90 bytes]\n\n",
+	} {
+		if !strings.Contains(dump, expected) {
+			t.Fatal("DebugDump failed: ", expected, "\n", dump)
+		}
+	}
+	if strings.Contains(dump, "secret") {
+		t.Fatal("DebugDump mask failed.")
+	}
+
+	// the authentication retries are dumped.
+	digestServer, _ := newTestDigestServer("auth")
+	defer digestServer.Close()
+	out.Reset()
+	dumpSession := NewSession()
+	dumpSession.WrapTransport((&DebugDump{Writer: &out, MaskHeaders: []string{}}).Wrap)
+	resp, err := dumpSession.Get(digestServer.URL, NewDigestAuth("user", "pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || !strings.Contains(out.String(), "HTTP/1.1 401 Unauthorized") ||
+		!strings.Contains(out.String(), "=== response 2 (") || !strings.Contains(out.String(), "Authorization: Digest ") {
+		t.Fatal("DebugDump retries failed: ", out.String())
+	}
+
+	// the request errors are dumped.
+	out.Reset()
+	errSession := NewSession(&SessionOptions{Debug: &DebugDump{Writer: &out}, Faults: Faults{{Kind: FaultReset, Probability: 1}}})
+	if _, err := errSession.Get(ts.URL); err == nil {
+		t.Fatal("DebugDump error failed.")
+	}
+	if !strings.Contains(out.String(), "=== error 1 (") || !strings.Contains(out.String(), "connection reset") {
+		t.Fatal("DebugDump error failed: ", out.String())
+	}
+}
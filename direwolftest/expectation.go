@@ -0,0 +1,213 @@
+// Package direwolftest provides utilities for testing the code using direwolf.
+//
+// Transport is an in-process stub transport for Session, and Server is a
+// scripted httptest server. Both of them answer the requests by the
+// expectations, and report the expected calls which were never made.
+package direwolftest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// TestingT is the subset of testing.T used by AssertExpectations.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Response is a canned response of Expectation. If Err is not nil, the
+// Transport returns it as the request error, and the Server closes the
+// connection.
+type Response struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte
+	Err        error
+}
+
+// Expectation is an expected request and its canned responses. It matches the
+// requests by method, url, headers and body.
+type Expectation struct {
+	method  string
+	url     *url.URL
+	headers http.Header
+	body    []byte
+	hasBody bool
+
+	responses []*Response
+	times     int // 0 means at least once
+	calls     int
+}
+
+// WithHeader expects the request has the header.
+func (e *Expectation) WithHeader(key, value string) *Expectation {
+	if e.headers == nil {
+		e.headers = http.Header{}
+	}
+	e.headers.Add(key, value)
+	return e
+}
+
+// WithBody expects the request body is the same as body.
+func (e *Expectation) WithBody(body string) *Expectation {
+	e.body, e.hasBody = []byte(body), true
+	return e
+}
+
+// Respond adds a canned response. The header key and value pairs are set to
+// the response. If there are several responses, they are returned in order as
+// a sequence, and the last one is repeated.
+func (e *Expectation) Respond(statusCode int, body string, keyValue ...string) *Expectation {
+	if len(keyValue)%2 != 0 {
+		panic("key and value must be pair")
+	}
+	headers := http.Header{}
+	for i := 0; i < len(keyValue); i += 2 {
+		headers.Add(keyValue[i], keyValue[i+1])
+	}
+	e.responses = append(e.responses, &Response{StatusCode: statusCode, Headers: headers, Body: []byte(body)})
+	return e
+}
+
+// RespondError adds a canned error, the request fails with it.
+func (e *Expectation) RespondError(err error) *Expectation {
+	e.responses = append(e.responses, &Response{Err: err})
+	return e
+}
+
+// Times expects the request is made exactly n times.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// String returns the description of the Expectation.
+func (e *Expectation) String() string {
+	return e.method + " " + e.url.String()
+}
+
+// match check whether the request matches the Expectation.
+func (e *Expectation) match(req *http.Request, body []byte) bool {
+	if e.method != req.Method || !matchURL(e.url, req.URL) {
+		return false
+	}
+	for key, values := range e.headers {
+		if !reflect.DeepEqual(values, req.Header[key]) {
+			return false
+		}
+	}
+	return !e.hasBody || bytes.Equal(e.body, body)
+}
+
+// matchURL check whether the request url matches the expected url. If the
+// expected url has no host, only the path and query are matched. The order
+// of query parameters does not matter.
+func matchURL(expected, actual *url.URL) bool {
+	if expected.Host != "" && (!strings.EqualFold(expected.Scheme, actual.Scheme) || !strings.EqualFold(expected.Host, actual.Host)) {
+		return false
+	}
+	path := expected.Path
+	if path == "" {
+		path = "/"
+	}
+	actualPath := actual.Path
+	if actualPath == "" {
+		actualPath = "/"
+	}
+	if path != actualPath {
+		return false
+	}
+	expectedQuery, actualQuery := expected.Query(), actual.Query()
+	if len(expectedQuery) == 0 && len(actualQuery) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(expectedQuery, actualQuery)
+}
+
+// script is the expectations of Transport and Server.
+type script struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	unexpected   []string
+}
+
+// expect adds an Expectation. It panics if the url is invalid.
+func (s *script) expect(method, URL string) *Expectation {
+	u, err := url.Parse(URL)
+	if err != nil {
+		panic("direwolftest: invalid url " + URL)
+	}
+	e := &Expectation{method: strings.ToUpper(method), url: u}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expectations = append(s.expectations, e)
+	return e
+}
+
+// respond finds the matching Expectation of the request, and returns its
+// response. It returns nil if the request is unexpected.
+func (s *script) respond(req *http.Request) (*Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.expectations {
+		if !e.match(req, body) || (e.times > 0 && e.calls >= e.times) {
+			continue
+		}
+		e.calls++
+		if len(e.responses) == 0 {
+			return &Response{StatusCode: http.StatusOK}, nil
+		}
+		i := e.calls - 1
+		if i >= len(e.responses) {
+			i = len(e.responses) - 1
+		}
+		return e.responses[i], nil
+	}
+	s.unexpected = append(s.unexpected, req.Method+" "+req.URL.String())
+	return nil, nil
+}
+
+// assertExpectations reports the expected calls which were never made, and
+// the unexpected requests.
+func (s *script) assertExpectations(t TestingT) bool {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ok := true
+	for _, e := range s.expectations {
+		if e.times == 0 && e.calls == 0 {
+			t.Errorf("direwolftest: expected call %s was never made", e)
+			ok = false
+		} else if e.times > 0 && e.calls != e.times {
+			t.Errorf("direwolftest: expected call %s %d times, but got %d", e, e.times, e.calls)
+			ok = false
+		}
+	}
+	for _, req := range s.unexpected {
+		t.Errorf("direwolftest: unexpected request %s", req)
+		ok = false
+	}
+	return ok
+}
+
+// unexpectedError is returned by Transport for unexpected requests.
+func unexpectedError(req *http.Request) error {
+	return fmt.Errorf("direwolftest: unexpected request %s %s", req.Method, req.URL)
+}
@@ -0,0 +1,64 @@
+package direwolftest
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// Server is a scripted httptest server, it answers the requests by the
+// expectations. Like this:
+//
+//	server := direwolftest.NewServer()
+//	defer server.Close()
+//	server.Expect("POST", "/login").WithBody("user=wolf").Respond(302, "", "Location", "/home")
+//	server.Expect("GET", "/home").Respond(200, "welcome")
+//	// send requests to server.URL ...
+//	server.AssertExpectations(t)
+//
+// The unexpected requests are answered with 501 Not Implemented.
+type Server struct {
+	*httptest.Server
+	script script
+}
+
+// NewServer starts and returns a new Server. The caller should call Close
+// when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Expect adds an expected request, the url is usually a path like "/users/1".
+func (s *Server) Expect(method, URL string) *Expectation {
+	return s.script.expect(method, URL)
+}
+
+// AssertExpectations reports the expected calls which were never made, and
+// the unexpected requests. It returns false if there is any.
+func (s *Server) AssertExpectations(t TestingT) bool {
+	t.Helper()
+	return s.script.assertExpectations(t)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	resp, err := s.script.respond(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if resp == nil {
+		http.Error(w, unexpectedError(r).Error(), http.StatusNotImplemented)
+		return
+	}
+	if resp.Err != nil {
+		panic(http.ErrAbortHandler) // close the connection.
+	}
+	for key, values := range resp.Headers {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
@@ -0,0 +1,40 @@
+package direwolftest
+
+import (
+	"testing"
+
+	"github.com/wnanbei/direwolf"
+)
+
+func TestServer(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Expect("POST", "/login").WithBody("user=wolf").Respond(302, "", "Location", "/home")
+	server.Expect("GET", "/home").Respond(200, "welcome")
+	server.Expect("GET", "/logout")
+
+	resp, err := direwolf.Post(server.URL+"/login", direwolf.Body("user=wolf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "welcome" || len(resp.History) != 1 {
+		t.Fatal("Server failed.")
+	}
+
+	resp, err = direwolf.Get(server.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 501 {
+		t.Fatal("Server unexpected request failed.")
+	}
+
+	ft := &fakeT{}
+	if server.AssertExpectations(ft) || len(ft.errors) != 2 {
+		t.Fatal("Server AssertExpectations failed: ", ft.errors)
+	}
+	if ft.errors[0] != "direwolftest: expected call GET /logout was never made" ||
+		ft.errors[1] != "direwolftest: unexpected request GET /missing" {
+		t.Fatal("Server AssertExpectations failed: ", ft.errors)
+	}
+}
@@ -0,0 +1,87 @@
+package direwolftest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/wnanbei/direwolf"
+)
+
+// Transport is an in-process stub transport, it answers the requests by the
+// expectations without network access. Like this:
+//
+//	stub := direwolftest.NewTransport()
+//	stub.Expect("GET", "https://api.example.com/users/1").
+//		WithHeader("Accept", "application/json").
+//		Respond(200, `{"id": 1}`, "Content-Type", "application/json")
+//	session := stub.Session()
+//	// send requests ...
+//	stub.AssertExpectations(t)
+//
+// The unexpected requests fail with an error.
+type Transport struct {
+	script script
+}
+
+// NewTransport new a Transport.
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// Expect adds an expected request. If the url has no host, such as
+// "/users/1", only the path and query are matched.
+func (t *Transport) Expect(method, URL string) *Expectation {
+	return t.script.expect(method, URL)
+}
+
+// Session returns a new Session which uses the Transport.
+func (t *Transport) Session() *direwolf.Session {
+	options := direwolf.DefaultSessionOptions()
+	options.Transport = t
+	return direwolf.NewSession(options)
+}
+
+// Wrap returns the Transport, so that it can replace the transport of an
+// existing Session by Session.WrapTransport.
+func (t *Transport) Wrap(next http.RoundTripper) http.RoundTripper {
+	return t
+}
+
+// AssertExpectations reports the expected calls which were never made, and
+// the unexpected requests. It returns false if there is any.
+func (t *Transport) AssertExpectations(tt TestingT) bool {
+	tt.Helper()
+	return t.script.assertExpectations(tt)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.script.respond(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, unexpectedError(req)
+	}
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	header := resp.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	return &http.Response{
+		Status:        strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode),
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}, nil
+}
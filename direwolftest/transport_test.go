@@ -0,0 +1,91 @@
+package direwolftest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/wnanbei/direwolf"
+)
+
+// fakeT records the errors of AssertExpectations.
+type fakeT struct {
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestTransport(t *testing.T) {
+	stub := NewTransport()
+	stub.Expect("GET", "https://api.example.com/users?id=1&page=2").
+		WithHeader("Accept", "application/json").
+		Respond(200, `{"id": 1}`, "Content-Type", "application/json")
+	stub.Expect("POST", "/users").WithBody("name=wolf").
+		Respond(503, "busy").
+		Respond(201, "created")
+
+	session := stub.Session()
+	resp, err := session.Get("https://api.example.com/users?page=2&id=1", direwolf.NewHeaders("Accept", "application/json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || resp.Text() != `{"id": 1}` || resp.Headers.Get("Content-Type") != "application/json" {
+		t.Fatal("Transport response failed.")
+	}
+
+	// the responses are returned as a sequence, the last one is repeated.
+	for _, expected := range []string{"busy", "created", "created"} {
+		resp, err = session.Post("https://api.example.com/users", direwolf.NewPostForm("name", "wolf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Text() != expected {
+			t.Fatal("Transport sequence failed: ", resp.Text())
+		}
+	}
+	if !stub.AssertExpectations(t) {
+		t.Fatal("Transport AssertExpectations failed.")
+	}
+
+	// the header does not match.
+	if _, err := session.Get("https://api.example.com/users?id=1&page=2"); err == nil {
+		t.Fatal("Transport unexpected request failed.")
+	}
+	ft := &fakeT{}
+	if stub.AssertExpectations(ft) || len(ft.errors) != 1 {
+		t.Fatal("Transport AssertExpectations unexpected request failed: ", ft.errors)
+	}
+}
+
+func TestTransportExpectations(t *testing.T) {
+	stub := NewTransport()
+	stub.Expect("GET", "/a").Times(2)
+	stub.Expect("GET", "/b")
+	errTimeout := errors.New("timeout")
+	stub.Expect("GET", "/c").RespondError(errTimeout)
+
+	session := direwolf.NewSession()
+	session.WrapTransport(stub.Wrap)
+	if _, err := session.Get("http://example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Get("http://example.com/c"); !errors.Is(err, errTimeout) {
+		t.Fatal("Transport RespondError failed: ", err)
+	}
+
+	ft := &fakeT{}
+	if stub.AssertExpectations(ft) {
+		t.Fatal("Transport AssertExpectations failed.")
+	}
+	expected := []string{
+		"direwolftest: expected call GET /a 2 times, but got 1",
+		"direwolftest: expected call GET /b was never made",
+	}
+	if fmt.Sprint(ft.errors) != fmt.Sprint(expected) {
+		t.Fatal("Transport AssertExpectations failed: ", ft.errors)
+	}
+}
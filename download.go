@@ -3,33 +3,42 @@ package direwolf
 import (
 	"bytes"
 	"context"
-	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptrace"
 	"strings"
 	"time"
 )
 
 // send is low level request method.
-func send(session *Session, req *Request) (*Response, error) {
+// The credentials are set by authz if it is not nil.
+func send(session *Session, req *Request, authz authorizer) (*Response, error) {
+	conf := session.snapshot() // use a snapshot of session settings, in case they are changed.
+
 	// Set timeout to request context.
-	// Default timeout is 30s.
+	// Default timeout is 30s, Request has higher priority.
+	timeouts := mergeTimeouts(req.Timeouts, conf.timeouts)
 	timeout := time.Second * 30
-	if req.Timeout > 0 {
+	if req.Timeouts != nil && req.Timeouts.Total > 0 {
+		timeout = req.Timeouts.Total
+	} else if req.Timeout > 0 {
 		timeout = time.Second * time.Duration(req.Timeout)
-	} else if session.Timeout > 0 {
-		timeout = time.Second * time.Duration(session.Timeout)
+	} else if conf.timeouts != nil && conf.timeouts.Total > 0 {
+		timeout = conf.timeouts.Total
+	} else if conf.timeout > 0 {
+		timeout = time.Second * time.Duration(conf.timeout)
 	}
 	ctx, timeoutCancel := context.WithTimeout(context.Background(), timeout)
+	timer := newPhaseTimer(timeouts, timeoutCancel)
 
 	// set proxy to request context.
 	if req.Proxy != nil {
 		ctx = context.WithValue(ctx, "http", req.Proxy.HTTP)
 		ctx = context.WithValue(ctx, "https", req.Proxy.HTTPS)
-	} else if session.Proxy != nil {
-		ctx = context.WithValue(ctx, "http", session.Proxy.HTTP)
-		ctx = context.WithValue(ctx, "https", session.Proxy.HTTPS)
+	} else if conf.proxy != nil {
+		ctx = context.WithValue(ctx, "http", conf.proxy.HTTP)
+		ctx = context.WithValue(ctx, "https", conf.proxy.HTTPS)
 	}
 
 	// set RedirectNum to request context.
@@ -42,6 +51,19 @@ func send(session *Session, req *Request) (*Response, error) {
 		ctx = context.WithValue(ctx, "redirectNum", 0)
 	}
 
+	// set RedirectPolicy to request context, Request has higher priority.
+	if req.RedirectPolicy != nil {
+		ctx = context.WithValue(ctx, "redirectPolicy", req.RedirectPolicy)
+	} else if conf.redirectPolicy != nil {
+		ctx = context.WithValue(ctx, "redirectPolicy", conf.redirectPolicy)
+	}
+
+	// mark the request is authenticated, so that the credentials will
+	// be removed when redirect to another host.
+	if authz != nil {
+		ctx = context.WithValue(ctx, "auth", true)
+	}
+
 	// Make new http.Request with context
 	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, nil)
 	if err != nil {
@@ -50,19 +72,36 @@ func send(session *Session, req *Request) (*Response, error) {
 	}
 
 	// Handle the Headers.
-	httpReq.Header = mergeHeaders(req.Headers, session.Headers)
+	httpReq.Header = mergeHeaders(req.Headers, conf.headers, req.Merge)
 
 	// Handle the DataForm, Body or JsonBody.
 	// Set right Content-Type.
+	var body []byte
 	if req.PostForm != nil {
 		httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		data := req.PostForm.URLEncode()
-		httpReq.Body = ioutil.NopCloser(strings.NewReader(data))
+		body = []byte(req.PostForm.URLEncode())
 	} else if req.Body != nil {
-		httpReq.Body = ioutil.NopCloser(bytes.NewReader(req.Body))
+		body = req.Body
 	} else if req.JsonBody != nil {
 		httpReq.Header.Set("Content-Type", "application/json")
-		httpReq.Body = ioutil.NopCloser(bytes.NewReader(req.JsonBody))
+		body = req.JsonBody
+	}
+
+	// Compress the body, Request has higher priority.
+	compress := req.Compress
+	if compress == nil {
+		compress = conf.compress
+	}
+	if compress != nil && compress.shouldCompress(len(body)) {
+		body, err = compressBody(compress.Encoding, body)
+		if err != nil {
+			timeoutCancel()
+			return nil, WrapErr(err, "compress request body failed")
+		}
+		httpReq.Header.Set("Content-Encoding", strings.ToLower(compress.Encoding))
+	}
+	if body != nil {
+		setBody(httpReq, body)
 	}
 
 	// Handle Cookies
@@ -72,41 +111,79 @@ func send(session *Session, req *Request) (*Response, error) {
 		}
 	}
 
-	resp, err := session.client.Do(httpReq) // do request
-	if err != nil {
-		if strings.Contains(err.Error(), "context deadline exceeded") { // check timeout error
+	// Set the credentials.
+	if authz != nil {
+		if err := authz.authorize(httpReq); err != nil {
 			timeoutCancel()
-			return nil, WrapErr(ErrTimeout, err.Error())
+			return nil, WrapErr(err, "set request credentials failed")
 		}
+	}
+
+	// Trace the request to record the timings, and find out which phase
+	// the request is timeout.
+	trace := newRequestTrace(timer)
+	httpReq = httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), trace.clientTrace()))
+
+	resp, err := conf.client.Do(httpReq) // do request
+	if err != nil {
+		timer.stop()
 		timeoutCancel()
-		return nil, WrapErr(err, "Request Error")
+		kind := timer.expiredKind()
+		if kind == nil {
+			kind = classifyError(err, trace.isHandshaking(), trace.isConnected())
+		}
+		return nil, WrapErr(&RequestError{Kind: kind, Method: req.Method, URL: req.URL, Err: err}, "Request Error")
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
 			panic(err)
 		}
 	}()
+	resp.Body = timer.idleReader(resp.Body)
 
 	response, err := buildResponse(req, resp)
 	if err != nil {
+		timer.stop()
 		timeoutCancel()
-		return nil, WrapErr(err, "build Response Error")
+		kind := timer.expiredKind()
+		if kind == nil {
+			kind = ErrReadBody
+			if isTimeout(err) {
+				kind = ErrBodyTimeout
+			}
+		}
+		return nil, WrapErr(&RequestError{Kind: kind, Method: req.Method, URL: req.URL, Err: err}, "build Response Error")
 	}
+	trace.finish()
+	response.Timings = trace.timings()
 
 	timeoutCancel() // cancel the timeout context after request successed.
 	return response, nil
 }
 
+// setBody set a replayable body to http.Request. GetBody is set, so that the
+// body can be resent on 307/308 redirects and internal retries.
+func setBody(httpReq *http.Request, body []byte) {
+	httpReq.ContentLength = int64(len(body))
+	if len(body) == 0 {
+		httpReq.Body = http.NoBody
+		httpReq.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return
+	}
+	httpReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	httpReq.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
 // buildResponse build response with http.Response after do request.
 func buildResponse(httpReq *Request, httpResp *http.Response) (*Response, error) {
 	content, err := ioutil.ReadAll(httpResp.Body)
 	if err != nil {
-		if !errors.Is(err, io.ErrUnexpectedEOF) { // Ignore Unexpected EOF error
-			return nil, WrapErr(err, "read Response.Body failed")
-		}
+		return nil, WrapErr(err, "read Response.Body failed")
 	}
 	return &Response{
-		URL:           httpReq.URL,
+		URL:           httpResp.Request.URL.String(),
 		StatusCode:    httpResp.StatusCode,
 		Proto:         httpResp.Proto,
 		Headers:       httpResp.Header,
@@ -114,22 +191,76 @@ func buildResponse(httpReq *Request, httpResp *http.Response) (*Response, error)
 		Request:       httpReq,
 		ContentLength: httpResp.ContentLength,
 		Content:       content,
+		History:       buildHistory(httpResp),
 		encoding:      "UTF-8",
 	}, nil
 }
 
+// buildHistory build the redirect history of the response.
+// http.Request.Response is the redirect response which caused this request
+// to be created, so we can find all of the redirect responses by it.
+func buildHistory(httpResp *http.Response) []*RedirectHop {
+	var history []*RedirectHop
+	for redirectResp := httpResp.Request.Response; redirectResp != nil; redirectResp = redirectResp.Request.Response {
+		hop := &RedirectHop{
+			StatusCode: redirectResp.StatusCode,
+			URL:        redirectResp.Request.URL.String(),
+			Headers:    redirectResp.Header,
+			Cookies:    redirectResp.Cookies(),
+		}
+		history = append([]*RedirectHop{hop}, history...)
+	}
+	return history
+}
+
+// mergeCookies merge Request cookies and Session cookies.
+// Request has higher priority, cookies of the same name are merged by Merge.
+func mergeCookies(c1, c2 Cookies, merge *Merge) Cookies {
+	c := Cookies{}
+	for _, cookie := range c2 {
+		switch merge.cookieMode(cookie.Name) {
+		case MergeDelete:
+			continue
+		case MergeReplace:
+			if hasCookie(c1, cookie.Name) {
+				continue
+			}
+		}
+		c = append(c, cookie)
+	}
+	return append(c, c1...)
+}
+
+// hasCookie check whether the cookie of the name is existed in cookies.
+func hasCookie(cookies Cookies, name string) bool {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // mergeHeaders merge Request headers and Session Headers.
-// Request has higher priority.
-func mergeHeaders(h1, h2 http.Header) http.Header {
+// Request has higher priority, headers of the same key are merged by Merge.
+func mergeHeaders(h1, h2 http.Header, merge *Merge) http.Header {
 	h := http.Header{}
 	for key, values := range h2 {
+		switch merge.headerMode(key) {
+		case MergeDelete:
+			continue
+		case MergeReplace:
+			if _, ok := h1[http.CanonicalHeaderKey(key)]; ok {
+				continue
+			}
+		}
 		for _, value := range values {
-			h.Set(key, value)
+			h.Add(key, value)
 		}
 	}
 	for key, values := range h1 {
 		for _, value := range values {
-			h.Set(key, value)
+			h.Add(key, value)
 		}
 	}
 	return h
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -140,3 +141,106 @@ func TestRedirect(t *testing.T) {
 		t.Fatal("Test TestRedirectError failed.")
 	}
 }
+
+func TestRedirectHistory(t *testing.T) {
+	redirectServer := newTestRedirectServer()
+	defer redirectServer.Close()
+
+	resp, err := Get(redirectServer.URL + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.URL != redirectServer.URL+"/" {
+		t.Fatal("Test Response.URL failed: ", resp.URL)
+	}
+	if len(resp.History) != 2 {
+		t.Fatal("Test Response.History failed.")
+	}
+	if resp.History[0].URL != redirectServer.URL+"/2" || resp.History[1].URL != redirectServer.URL+"/1" {
+		t.Fatal("Test Response.History failed.")
+	}
+	if resp.History[0].StatusCode != 302 || resp.History[0].Headers.Get("Location") != "/1" {
+		t.Fatal("Test Response.History failed.")
+	}
+
+	cookieServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "token", Value: "direwolf"})
+			http.Redirect(w, r, "/", 301)
+		}
+	}))
+	defer cookieServer.Close()
+	resp, err = Get(cookieServer.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.History) != 1 || resp.History[0].StatusCode != 301 {
+		t.Fatal("Test Response.History failed.")
+	}
+	if resp.History[0].Cookies[0].Name != "token" || resp.History[0].Cookies[0].Value != "direwolf" {
+		t.Fatal("Test Response.History cookies failed.")
+	}
+
+	resp, err = Get(cookieServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.History != nil || resp.URL != cookieServer.URL {
+		t.Fatal("Test Response.History failed.")
+	}
+}
+
+func TestMergeHeaders(t *testing.T) {
+	sessionHeaders := http.Header{}
+	sessionHeaders.Add("Accept", "text/html")
+	sessionHeaders.Add("Accept", "application/json")
+	sessionHeaders.Add("User-Agent", "direwolf")
+	sessionHeaders.Add("X-Token", "token")
+	reqHeaders := http.Header{}
+	reqHeaders.Add("Accept", "text/plain")
+
+	h := mergeHeaders(reqHeaders, sessionHeaders, nil)
+	if len(h["Accept"]) != 1 || h.Get("Accept") != "text/plain" || h.Get("User-Agent") != "direwolf" {
+		t.Fatal("mergeHeaders replace failed: ", h)
+	}
+
+	merge := &Merge{Headers: map[string]MergeMode{"accept": MergeAppend, "X-Token": MergeDelete}}
+	h = mergeHeaders(reqHeaders, sessionHeaders, merge)
+	if fmt.Sprint(h["Accept"]) != "[text/html application/json text/plain]" {
+		t.Fatal("mergeHeaders append failed: ", h)
+	}
+	if _, ok := h["X-Token"]; ok || h.Get("User-Agent") != "direwolf" {
+		t.Fatal("mergeHeaders delete failed: ", h)
+	}
+
+	// multi-valued request headers are kept.
+	reqHeaders.Add("Accept", "text/xml")
+	h = mergeHeaders(reqHeaders, sessionHeaders, nil)
+	if fmt.Sprint(h["Accept"]) != "[text/plain text/xml]" {
+		t.Fatal("mergeHeaders failed: ", h)
+	}
+}
+
+func TestMergeCookies(t *testing.T) {
+	sessionCookies := NewCookies("a", "1", "b", "2", "c", "3")
+	reqCookies := NewCookies("a", "10", "b", "20")
+
+	c := mergeCookies(reqCookies, sessionCookies, nil)
+	if cookiesString(c) != "c=3;a=10;b=20" {
+		t.Fatal("mergeCookies replace failed: ", cookiesString(c))
+	}
+
+	merge := &Merge{Cookies: map[string]MergeMode{"b": MergeAppend, "c": MergeDelete}}
+	c = mergeCookies(reqCookies, sessionCookies, merge)
+	if cookiesString(c) != "b=2;a=10;b=20" {
+		t.Fatal("mergeCookies merge failed: ", cookiesString(c))
+	}
+}
+
+func cookiesString(cookies Cookies) string {
+	var s []string
+	for _, cookie := range cookies {
+		s = append(s, cookie.Name+"="+cookie.Value)
+	}
+	return strings.Join(s, ";")
+}
@@ -1,18 +1,141 @@
 package direwolf
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"runtime"
 	"strconv"
+	"syscall"
 	"time"
 )
 
 var (
 	ErrRequestBody = errors.New("request body can`t coexists with PostForm")
 	ErrTimeout     = errors.New("reqeust timeout")
+
+	ErrCompressEncoding   = errors.New("unsupported compress encoding")
+	ErrRedirectNotAllowed = errors.New("redirect is not allowed by RedirectPolicy")
+	ErrSessionOptions     = errors.New("invalid session options")
+	ErrAuthChallenge      = errors.New("invalid authentication challenge")
+	ErrAuthCode           = errors.New("oauth2 authorization code flow failed")
+	ErrSignature          = errors.New("sign request failed")
+	ErrJWT                = errors.New("invalid JWT")
+	ErrNetrc              = errors.New("invalid netrc")
+	ErrCurl               = errors.New("invalid curl command")
+	ErrHARNotFound        = errors.New("no matching HAR entry")
+	ErrCassetteNotFound   = errors.New("no matching cassette interaction")
+
+	// Network errors, they are the Kind of RequestError.
+	ErrRequest        = errors.New("request failed")
+	ErrDNS            = errors.New("dns lookup failed")
+	ErrConnRefused    = errors.New("connection refused")
+	ErrTLS            = errors.New("tls handshake failed")
+	ErrProxy          = errors.New("proxy failed")
+	ErrConnectTimeout = errors.New("connect timeout")
+	ErrTLSTimeout     = errors.New("tls handshake timeout")
+	ErrHeaderTimeout  = errors.New("response header timeout")
+	ErrBodyTimeout    = errors.New("response body read timeout")
+	ErrRedirect       = errors.New("redirect limit exceeded")
+	ErrReadBody       = errors.New("read response body failed")
 )
 
+// RequestError is returned when the request failed in network level.
+// Kind is one of the network errors, such as ErrDNS, ErrTLS and
+// ErrConnectTimeout, so you can check it by errors.Is. All timeout
+// kinds also match ErrTimeout.
+type RequestError struct {
+	Kind   error
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %v", e.Method, e.URL, e.Kind.Error(), e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the kind of RequestError matches target.
+func (e *RequestError) Is(target error) bool {
+	if target == e.Kind {
+		return true
+	}
+	if target == ErrTimeout {
+		return e.Kind == ErrConnectTimeout || e.Kind == ErrTLSTimeout ||
+			e.Kind == ErrHeaderTimeout || e.Kind == ErrBodyTimeout
+	}
+	return false
+}
+
+// classifyError find out the kind of network error returned by http.Client.Do.
+// handshaking specifies whether the tls handshake is in progress, connected
+// specifies whether the connection has been established.
+func classifyError(err error, handshaking, connected bool) error {
+	var redirectErr *RedirectError
+	if errors.As(err, &redirectErr) {
+		return ErrRedirect
+	}
+	if isTimeout(err) {
+		if connected {
+			return ErrHeaderTimeout
+		}
+		if handshaking {
+			return ErrTLSTimeout
+		}
+		return ErrConnectTimeout
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		return ErrProxy
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrDNS
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrConnRefused
+	}
+	if isTLSError(err) {
+		return ErrTLS
+	}
+	return ErrRequest
+}
+
+// isTimeout check whether err is a timeout error.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isTLSError check whether err happened in tls handshake or certificate verification.
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &recordErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && (opErr.Op == "remote error" || opErr.Op == "local error") {
+		return true // tls alert
+	}
+	return false
+}
+
 type RedirectError struct {
 	RedirectNum int
 }
@@ -21,6 +144,40 @@ func (e *RedirectError) Error() string {
 	return "exceeded the maximum number of redirects: " + strconv.Itoa(e.RedirectNum)
 }
 
+// httpErrorBodySize is the max length of body snippet in HTTPError.
+const httpErrorBodySize = 512
+
+// HTTPError is returned when the status code of response is 4xx or 5xx.
+// You can get it by errors.As.
+type HTTPError struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte // a snippet of the response body
+	Request    *Request
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("http error: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Request != nil {
+		msg += ", " + e.Request.Method + " " + e.Request.URL
+	}
+	return msg
+}
+
+// newHTTPError build a HTTPError with response.
+func newHTTPError(resp *Response) *HTTPError {
+	body := resp.Content
+	if len(body) > httpErrorBodySize {
+		body = body[:httpErrorBodySize]
+	}
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Headers,
+		Body:       body,
+		Request:    resp.Request,
+	}
+}
+
 type Error struct {
 	// wrapped error
 	err error
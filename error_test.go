@@ -2,7 +2,14 @@ package direwolf
 
 import (
 	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
 func TestError(t *testing.T) {
@@ -14,3 +21,131 @@ func TestError(t *testing.T) {
 		t.Fatal("Test errors.Is failed.")
 	}
 }
+
+func newTestNetworkErrorServer() *httptest.Server {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.GET("/header", func(c *gin.Context) {
+		time.Sleep(time.Second * 2)
+		c.String(200, "successed")
+	})
+	router.GET("/body", func(c *gin.Context) {
+		c.Header("Content-Length", "100")
+		c.Writer.WriteHeader(200)
+		c.Writer.Flush()
+		time.Sleep(time.Second * 2)
+	})
+	router.GET("/malformed", func(c *gin.Context) {
+		conn, buf, err := c.Writer.Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n")
+		buf.Flush()
+	})
+	router.GET("/short", func(c *gin.Context) {
+		c.Header("Content-Length", "100")
+		c.String(200, "short body")
+	})
+	router.GET("/redirect", func(c *gin.Context) {
+		c.Redirect(302, "/redirect")
+	})
+	ts := httptest.NewServer(router)
+	return ts
+}
+
+// closedURL returns a url that no one is listening on.
+func closedURL(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return "http://" + addr
+}
+
+// neverHandshakeAddr returns the address of a tcp server which accepts the
+// connections but never finishes the tls handshake.
+func neverHandshakeAddr(t *testing.T) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	return listener.Addr().String(), func() { listener.Close() }
+}
+
+func TestRequestError(t *testing.T) {
+	ts := newTestNetworkErrorServer()
+	defer ts.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	tests := []struct {
+		url  string
+		args []RequestOption
+		kind error
+	}{
+		{"http://direwolf.invalid", nil, ErrDNS},
+		{closedURL(t), nil, ErrConnRefused},
+		{tlsServer.URL, nil, ErrTLS},
+		{ts.URL, []RequestOption{&Proxy{HTTP: closedURL(t)}}, ErrProxy},
+		{ts.URL + "/header", []RequestOption{Timeout(1)}, ErrHeaderTimeout},
+		{ts.URL + "/body", []RequestOption{Timeout(1)}, ErrBodyTimeout},
+		{ts.URL + "/malformed", nil, ErrReadBody},
+		{ts.URL + "/short", nil, ErrReadBody},
+		{ts.URL + "/redirect", []RequestOption{RedirectNum(2)}, ErrRedirect},
+	}
+	for _, test := range tests {
+		_, err := Get(test.url, test.args...)
+		if !errors.Is(err, test.kind) {
+			t.Fatalf("RequestError kind failed: %s, %v", test.kind, err)
+		}
+		var reqErr *RequestError
+		if !errors.As(err, &reqErr) || reqErr.Method != "GET" || reqErr.URL != test.url {
+			t.Fatal("RequestError failed: ", err)
+		}
+	}
+
+	_, err := Get(ts.URL + "/short")
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("RequestError short body failed: ", err)
+	}
+
+	_, err = Get(ts.URL+"/header", Timeout(1))
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatal("RequestError timeout failed: ", err)
+	}
+	_, err = Get(ts.URL+"/redirect", RedirectNum(2))
+	var redirectErr *RedirectError
+	if !errors.As(err, &redirectErr) {
+		t.Fatal("RequestError redirect failed: ", err)
+	}
+}
+
+func TestRequestErrorTLSTimeout(t *testing.T) {
+	addr, closeListener := neverHandshakeAddr(t)
+	defer closeListener()
+
+	options := DefaultSessionOptions()
+	options.TLSHandshakeTimeout = 100 * time.Millisecond
+	_, err := NewSession(options).Get("https://" + addr)
+	if !errors.Is(err, ErrTLSTimeout) {
+		t.Fatal("RequestError tls timeout failed: ", err)
+	}
+
+	_, err = NewSession().Get("https://"+addr, &Timeouts{Total: 100 * time.Millisecond})
+	if !errors.Is(err, ErrTLSTimeout) {
+		t.Fatal("RequestError tls timeout failed: ", err)
+	}
+}
@@ -0,0 +1,227 @@
+package direwolf
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FaultKind is the kind of Fault.
+type FaultKind int
+
+const (
+	// FaultLatency delays the request by Delay before it is sent.
+	FaultLatency FaultKind = iota
+	// FaultReset fails the request with a connection reset error.
+	FaultReset
+	// FaultTimeout fails the request with a timeout error after Delay. If
+	// Delay is zero, it hangs until the request is canceled or timeout.
+	FaultTimeout
+	// FaultTruncate truncates the response body after TruncateAt bytes, and
+	// the read fails with io.ErrUnexpectedEOF, like a real truncated body.
+	FaultTruncate
+	// FaultStatus returns a response of StatusCode without sending the request.
+	FaultStatus
+	// FaultSlowBody streams the response body byte by byte, Delay per byte.
+	FaultSlowBody
+)
+
+// Fault is a network fault injected to the requests, it is used to test the
+// retry and timeout handling.
+//
+// The fault applies to the requests whose host matches Host and whose url
+// matches URL. Host is a glob pattern like "*.example.com", both of them match
+// all of the requests if they are empty. The fault is injected with
+// Probability, which is decided by a random source of Seed, so that the
+// faults are deterministic. Probability 1 means always, and 0 means never, so
+// the zero Fault is not injected.
+type Fault struct {
+	Kind        FaultKind
+	Host        string
+	URL         *regexp.Regexp
+	Probability float64
+	Seed        int64
+
+	Delay      time.Duration // FaultLatency, FaultTimeout and FaultSlowBody
+	StatusCode int           // FaultStatus
+	TruncateAt int           // FaultTruncate
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// match check whether the fault applies to the request.
+func (fault *Fault) match(req *http.Request) bool {
+	if fault.Host != "" {
+		if ok, _ := path.Match(fault.Host, req.URL.Hostname()); !ok {
+			return false
+		}
+	}
+	if fault.URL != nil && !fault.URL.MatchString(req.URL.String()) {
+		return false
+	}
+	if fault.Probability <= 0 {
+		return false
+	}
+	if fault.Probability >= 1 {
+		return true
+	}
+	fault.mu.Lock()
+	defer fault.mu.Unlock()
+	if fault.rand == nil {
+		fault.rand = rand.New(rand.NewSource(fault.Seed))
+	}
+	return fault.rand.Float64() < fault.Probability
+}
+
+// Faults are the faults injected to the requests of Session, one of the
+// SessionOptions. They are checked in order, latency and slow body can be
+// combined with the others. It can be set to an existing Session by
+// WrapTransport. Like this:
+//
+//	session.WrapTransport(dw.Faults{
+//		{Kind: dw.FaultLatency, Delay: time.Second, Probability: 1},
+//		{Kind: dw.FaultStatus, StatusCode: 503, Probability: 0.3, Seed: 1},
+//	}.Wrap)
+type Faults []*Fault
+
+// Wrap returns a http.RoundTripper which injects the faults to next.
+func (faults Faults) Wrap(next http.RoundTripper) http.RoundTripper {
+	return &faultTransport{faults: faults, next: next}
+}
+
+// faultTransport injects the faults to the requests.
+type faultTransport struct {
+	faults Faults
+	next   http.RoundTripper
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var bodyFaults []*Fault
+	for _, fault := range t.faults {
+		if !fault.match(req) {
+			continue
+		}
+		switch fault.Kind {
+		case FaultLatency:
+			if err := sleepContext(req, fault.Delay); err != nil {
+				return nil, err
+			}
+		case FaultReset:
+			closeRequestBody(req)
+			return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+		case FaultTimeout:
+			closeRequestBody(req)
+			if fault.Delay <= 0 {
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			}
+			if err := sleepContext(req, fault.Delay); err != nil {
+				return nil, err
+			}
+			return nil, &net.OpError{Op: "read", Net: "tcp", Err: faultTimeoutError{}}
+		case FaultStatus:
+			closeRequestBody(req)
+			body := []byte(http.StatusText(fault.StatusCode))
+			return &http.Response{
+				Status:        strconv.Itoa(fault.StatusCode) + " " + http.StatusText(fault.StatusCode),
+				StatusCode:    fault.StatusCode,
+				Proto:         "HTTP/1.1",
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+				Body:          ioutil.NopCloser(bytes.NewReader(body)),
+				ContentLength: int64(len(body)),
+				Request:       req,
+			}, nil
+		case FaultTruncate, FaultSlowBody:
+			bodyFaults = append(bodyFaults, fault)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, fault := range bodyFaults {
+		if fault.Kind == FaultTruncate {
+			resp.Body = &truncatedBody{ReadCloser: resp.Body, remain: fault.TruncateAt}
+		} else {
+			resp.Body = &slowBody{ReadCloser: resp.Body, req: req, delay: fault.Delay}
+		}
+	}
+	return resp, nil
+}
+
+// closeRequestBody closes the body of request which is not sent.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// sleepContext sleeps d, it returns the error if the request is canceled.
+func sleepContext(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// faultTimeoutError is the injected timeout error.
+type faultTimeoutError struct{}
+
+func (faultTimeoutError) Error() string   { return "i/o timeout (injected)" }
+func (faultTimeoutError) Timeout() bool   { return true }
+func (faultTimeoutError) Temporary() bool { return true }
+
+// truncatedBody fails with io.ErrUnexpectedEOF after remain bytes.
+type truncatedBody struct {
+	io.ReadCloser
+	remain int
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remain <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if len(p) > b.remain {
+		p = p[:b.remain]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remain -= n
+	if err == io.EOF { // the body is shorter than remain.
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// slowBody returns the body byte by byte, delay per byte.
+type slowBody struct {
+	io.ReadCloser
+	req   *http.Request
+	delay time.Duration
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := sleepContext(b.req, b.delay); err != nil {
+		return 0, err
+	}
+	return b.ReadCloser.Read(p[:1])
+}
@@ -0,0 +1,123 @@
+package direwolf
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newTestFaultServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello world"))
+	}))
+}
+
+func newFaultSession(faults ...*Fault) *Session {
+	options := DefaultSessionOptions()
+	options.Faults = faults
+	return NewSession(options)
+}
+
+func TestFaults(t *testing.T) {
+	ts := newTestFaultServer()
+	defer ts.Close()
+
+	start := time.Now()
+	resp, err := newFaultSession(&Fault{Kind: FaultLatency, Probability: 1, Delay: 100 * time.Millisecond}).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "hello world" || time.Since(start) < 100*time.Millisecond {
+		t.Fatal("FaultLatency failed.")
+	}
+
+	_, err = newFaultSession(&Fault{Kind: FaultReset, Probability: 1}).Get(ts.URL)
+	if !errors.Is(err, syscall.ECONNRESET) || !errors.Is(err, ErrRequest) {
+		t.Fatal("FaultReset failed: ", err)
+	}
+
+	_, err = newFaultSession(&Fault{Kind: FaultTimeout, Probability: 1}).Get(ts.URL, &Timeouts{Total: 100 * time.Millisecond})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatal("FaultTimeout failed: ", err)
+	}
+	_, err = newFaultSession(&Fault{Kind: FaultTimeout, Probability: 1, Delay: 10 * time.Millisecond}).Get(ts.URL)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatal("FaultTimeout with delay failed: ", err)
+	}
+
+	session := newFaultSession(&Fault{Kind: FaultStatus, Probability: 1, StatusCode: 503, URL: regexp.MustCompile(`/api/`)})
+	resp, err = session.Get(ts.URL + "/api/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 503 {
+		t.Fatal("FaultStatus failed.")
+	}
+	resp, err = session.Get(ts.URL + "/home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatal("Fault URL pattern failed.")
+	}
+
+	_, err = newFaultSession(&Fault{Kind: FaultTruncate, Probability: 1, TruncateAt: 5}).Get(ts.URL)
+	if !errors.Is(err, ErrReadBody) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("FaultTruncate failed: ", err)
+	}
+
+	start = time.Now()
+	resp, err = newFaultSession(&Fault{Kind: FaultSlowBody, Probability: 1, Delay: 10 * time.Millisecond}).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "hello world" || time.Since(start) < 110*time.Millisecond {
+		t.Fatal("FaultSlowBody failed.")
+	}
+
+	resp, err = newFaultSession(&Fault{Kind: FaultReset, Probability: 1, Host: "*.example.com"}).Get(ts.URL)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatal("Fault Host pattern failed.")
+	}
+
+	resp, err = newFaultSession(&Fault{Kind: FaultReset}).Get(ts.URL)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatal("Fault zero Probability failed.")
+	}
+}
+
+func TestFaultProbability(t *testing.T) {
+	ts := newTestFaultServer()
+	defer ts.Close()
+
+	run := func() []int {
+		session := newFaultSession(&Fault{Kind: FaultStatus, StatusCode: 500, Probability: 0.5, Seed: 42})
+		var codes []int
+		for i := 0; i < 20; i++ {
+			resp, err := session.Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			codes = append(codes, resp.StatusCode)
+		}
+		return codes
+	}
+	codes1, codes2 := run(), run()
+	failed := 0
+	for i := range codes1 {
+		if codes1[i] != codes2[i] {
+			t.Fatal("Fault seed is not deterministic.")
+		}
+		if codes1[i] == 500 {
+			failed++
+		}
+	}
+	if failed == 0 || failed == 20 {
+		t.Fatal("Fault probability failed: ", failed)
+	}
+}
@@ -1,14 +1,17 @@
 module github.com/wnanbei/direwolf
 
-go 1.12
+go 1.14
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.0
+	github.com/andybalholm/brotli v1.0.0
 	github.com/andybalholm/cascadia v1.1.0 // indirect
 	github.com/gin-gonic/gin v1.5.0
 	github.com/json-iterator/go v1.1.7
+	github.com/klauspost/compress v1.8.2
 	github.com/tidwall/gjson v1.3.5
 	github.com/valyala/fasthttp v1.6.0
+	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
 	golang.org/x/net v0.0.0-20191028085509-fe3aa8a45271
 	golang.org/x/text v0.3.2
 )
@@ -0,0 +1,525 @@
+package direwolf
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HAR is the HTTP Archive 1.2, which records the requests and responses.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root of HAR.
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+// HARCreator is the application which creates the HAR.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is an exported HTTP request.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // milliseconds
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+}
+
+// HARRequest is the request of HAREntry.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse is the response of HAREntry.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+	Comment     string         `json:"comment,omitempty"` // the error if the request failed
+}
+
+// HARCookie is the cookie of HARRequest and HARResponse.
+type HARCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+// HARNameValue is the header or query string pair.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is the body of HARRequest.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent is the body of HARResponse. Text is base64 encoded if Encoding
+// is "base64".
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings is the timings of HAREntry in milliseconds, -1 means it does not
+// apply to the request.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// ReadHAR reads the HAR file.
+func ReadHAR(path string) (*HAR, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, WrapErr(err, "open HAR file failed")
+	}
+	defer f.Close()
+	har := &HAR{}
+	if err := json.NewDecoder(f).Decode(har); err != nil {
+		return nil, WrapErr(err, "decode HAR file failed")
+	}
+	return har, nil
+}
+
+// WriteFile writes the HAR to file.
+func (har *HAR) WriteFile(path string) error {
+	data, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return WrapErr(err, "encode HAR failed")
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return WrapErr(err, "write HAR file failed")
+	}
+	return nil
+}
+
+// Requests converts the entries to Requests, so that they can be replayed by
+// Session.Send. Redirects are not followed, because the redirected requests
+// are recorded as entries too. Host, Content-Length, Accept-Encoding and
+// HTTP/2 pseudo headers are not copied, they are set by the http client.
+func (har *HAR) Requests() []*Request {
+	requests := make([]*Request, 0, len(har.Log.Entries))
+	for _, entry := range har.Log.Entries {
+		// the 3xx response is returned as it is recorded.
+		req := &Request{
+			Method:         entry.Request.Method,
+			URL:            entry.Request.URL,
+			Headers:        http.Header{},
+			RedirectNum:    -1,
+			RedirectPolicy: &RedirectPolicy{ReturnLast: true},
+		}
+		for _, header := range entry.Request.Headers {
+			switch http.CanonicalHeaderKey(header.Name) {
+			case "Host", "Content-Length", "Accept-Encoding":
+				continue
+			}
+			if strings.HasPrefix(header.Name, ":") {
+				continue
+			}
+			req.Headers.Add(header.Name, header.Value)
+		}
+		if entry.Request.PostData != nil {
+			req.Body = []byte(entry.Request.PostData.Text)
+		}
+		requests = append(requests, req)
+	}
+	return requests
+}
+
+// ReplayHAR sends the requests of the entries by the Session, and returns
+// their responses.
+func ReplayHAR(session *Session, har *HAR) ([]*Response, error) {
+	requests := har.Requests()
+	responses := make([]*Response, 0, len(requests))
+	for _, req := range requests {
+		resp, err := session.Send(req)
+		if err != nil {
+			return responses, WrapErrf(err, "replay %s %s failed", req.Method, req.URL)
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+// HARRecorder records the requests of Session into HAR. It wraps the transport
+// of Session, every request is recorded, including redirects and retries.
+// Like this:
+//
+//	recorder := dw.NewHARRecorder()
+//	session.WrapTransport(recorder.Wrap)
+//	// send requests ...
+//	recorder.HAR().WriteFile("session.har")
+type HARRecorder struct {
+	mu      sync.Mutex
+	entries []*HAREntry
+}
+
+// NewHARRecorder new a HARRecorder.
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{}
+}
+
+// Wrap returns a http.RoundTripper which records the requests sent by next.
+func (recorder *HARRecorder) Wrap(next http.RoundTripper) http.RoundTripper {
+	return &harRecordTransport{recorder: recorder, next: next}
+}
+
+// HAR returns the HAR of the recorded entries.
+func (recorder *HARRecorder) HAR() *HAR {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "direwolf", Version: "1.0"},
+		Entries: append([]*HAREntry{}, recorder.entries...),
+	}}
+}
+
+// Reset removes the recorded entries.
+func (recorder *HARRecorder) Reset() {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.entries = nil
+}
+
+func (recorder *HARRecorder) add(entry *HAREntry) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.entries = append(recorder.entries, entry)
+}
+
+// harRecordTransport records the requests into HARRecorder.
+type harRecordTransport struct {
+	recorder *HARRecorder
+	next     http.RoundTripper
+}
+
+func (t *harRecordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	entry := &HAREntry{Request: harRequest(req, body)}
+
+	// record the timings of each phase.
+	var dnsStart, dnsDone, connectStart, connectDone, tlsStart, tlsDone, gotConn, wroteRequest, firstByte time.Time
+	var mu sync.Mutex
+	mark := func(t *time.Time) {
+		mu.Lock()
+		*t = time.Now()
+		mu.Unlock()
+	}
+	trace := &httptrace.ClientTrace{
+		DNSStart:     func(httptrace.DNSStartInfo) { mark(&dnsStart) },
+		DNSDone:      func(httptrace.DNSDoneInfo) { mark(&dnsDone) },
+		ConnectStart: func(string, string) { mark(&connectStart) },
+		ConnectDone: func(network, addr string, err error) {
+			mark(&connectDone)
+			if host, _, err := net.SplitHostPort(addr); err == nil {
+				mu.Lock()
+				entry.ServerIPAddress = host
+				mu.Unlock()
+			}
+		},
+		TLSHandshakeStart:    func() { mark(&tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { mark(&tlsDone) },
+		GotConn:              func(httptrace.GotConnInfo) { mark(&gotConn) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { mark(&wroteRequest) },
+		GotFirstResponseByte: func() { mark(&firstByte) },
+	}
+	start := time.Now()
+	entry.StartedDateTime = start
+	resp, err := t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		entry.Response = HARResponse{Cookies: []HARCookie{}, Headers: []HARNameValue{}, HeadersSize: -1, BodySize: -1, Comment: err.Error()}
+		entry.Time = milliseconds(time.Since(start))
+		entry.Timings = HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+		t.recorder.add(entry)
+		return nil, err
+	}
+
+	// read the body, so that it can be recorded.
+	content, readErr := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(content), errReader{readErr}))
+	end := time.Now()
+
+	entry.Response = harResponse(resp, content)
+	entry.Time = milliseconds(end.Sub(start))
+	mu.Lock()
+	entry.Timings = HARTimings{
+		Blocked: -1,
+		DNS:     harPhase(dnsStart, dnsDone),
+		Connect: harPhase(connectStart, connectDone),
+		SSL:     harPhase(tlsStart, tlsDone),
+		Send:    harPhase(gotConn, wroteRequest),
+		Wait:    harPhase(wroteRequest, firstByte),
+		Receive: harPhase(firstByte, end),
+	}
+	if entry.Timings.Connect >= 0 && entry.Timings.SSL >= 0 { // connect includes ssl in HAR.
+		entry.Timings.Connect += entry.Timings.SSL
+	}
+	mu.Unlock()
+	t.recorder.add(entry)
+	return resp, nil
+}
+
+// errReader returns the error after the body is read, it keeps the error of
+// reading the original body.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
+
+// readRequestBody reads the body of http.Request without consuming it.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, WrapErr(err, "read request body failed")
+		}
+		defer body.Close()
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, WrapErr(err, "read request body failed")
+		}
+		return data, nil
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, WrapErr(err, "read request body failed")
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// harRequest converts http.Request to HARRequest.
+func harRequest(req *http.Request, body []byte) HARRequest {
+	harReq := HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARCookie{},
+		Headers:     harHeaders(req.Header),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+	if req.Host != "" {
+		harReq.Headers = append([]HARNameValue{{Name: "Host", Value: req.Host}}, harReq.Headers...)
+	}
+	for _, cookie := range req.Cookies() {
+		harReq.Cookies = append(harReq.Cookies, HARCookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	for key, values := range req.URL.Query() {
+		for _, value := range values {
+			harReq.QueryString = append(harReq.QueryString, HARNameValue{Name: key, Value: value})
+		}
+	}
+	if body != nil {
+		harReq.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return harReq
+}
+
+// harResponse converts http.Response to HARResponse.
+func harResponse(resp *http.Response, content []byte) HARResponse {
+	harResp := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []HARCookie{},
+		Headers:     harHeaders(resp.Header),
+		Content: HARContent{
+			Size:     int64(len(content)),
+			MimeType: resp.Header.Get("Content-Type"),
+		},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    int64(len(content)),
+	}
+	harResp.Content.Text, harResp.Content.Encoding = encodeBody(content)
+	for _, cookie := range resp.Cookies() {
+		harCookie := HARCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			HTTPOnly: cookie.HttpOnly,
+			Secure:   cookie.Secure,
+		}
+		if !cookie.Expires.IsZero() {
+			expires := cookie.Expires
+			harCookie.Expires = &expires
+		}
+		harResp.Cookies = append(harResp.Cookies, harCookie)
+	}
+	return harResp
+}
+
+// harHeaders converts http.Header to HARNameValue list.
+func harHeaders(header http.Header) []HARNameValue {
+	headers := []HARNameValue{}
+	for key, values := range header {
+		for _, value := range values {
+			headers = append(headers, HARNameValue{Name: key, Value: value})
+		}
+	}
+	return headers
+}
+
+// harPhase returns the milliseconds of the phase, -1 if it did not happen.
+func harPhase(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return -1
+	}
+	return milliseconds(end.Sub(start))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// HARTransport is a http.RoundTripper which answers the requests from the HAR,
+// it is used to stub the network by the recorded responses. Like this:
+//
+//	session := dw.NewSession(&dw.SessionOptions{Transport: dw.NewHARTransport(har)})
+//
+// The requests are matched by method and url. If there are several matching
+// entries, they are returned in order, and the last one is repeated. If there
+// is no matching entry, the request is sent by Fallback, or ErrHARNotFound is
+// returned if Fallback is nil. The response headers are replayed as they are,
+// including Content-Encoding, note that the browsers save the decoded body.
+type HARTransport struct {
+	Fallback http.RoundTripper
+
+	mu      sync.Mutex
+	entries map[string][]*HAREntry
+	served  map[string]int
+}
+
+// NewHARTransport new a HARTransport.
+func NewHARTransport(har *HAR) *HARTransport {
+	t := &HARTransport{entries: make(map[string][]*HAREntry), served: make(map[string]int)}
+	for _, entry := range har.Log.Entries {
+		key := entry.Request.Method + " " + entry.Request.URL
+		t.entries[key] = append(t.entries[key], entry)
+	}
+	return t
+}
+
+func (t *HARTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.String()
+	t.mu.Lock()
+	entries := t.entries[key]
+	var entry *HAREntry
+	if len(entries) > 0 {
+		i := t.served[key]
+		if i >= len(entries) {
+			i = len(entries) - 1
+		}
+		entry = entries[i]
+		t.served[key]++
+	}
+	t.mu.Unlock()
+
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if entry == nil {
+		if t.Fallback != nil {
+			return t.Fallback.RoundTrip(req)
+		}
+		return nil, WrapErrf(ErrHARNotFound, "%s %s", req.Method, req.URL)
+	}
+	if entry.Response.Status == 0 {
+		return nil, WrapErrf(ErrHARNotFound, "%s %s failed: %s", req.Method, req.URL, entry.Response.Comment)
+	}
+
+	content, err := decodeBody(entry.Response.Content.Text, entry.Response.Content.Encoding)
+	if err != nil {
+		return nil, WrapErr(err, "decode HAR content failed")
+	}
+	header := http.Header{}
+	for _, h := range entry.Response.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	// Content-Encoding is kept, the body is still encoded if it is recorded.
+	header.Set("Content-Length", strconv.Itoa(len(content)))
+
+	proto := entry.Response.HTTPVersion
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		proto, major, minor = "HTTP/1.1", 1, 1
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(entry.Response.Status) + " " + entry.Response.StatusText,
+		StatusCode:    entry.Response.Status,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(content)),
+		ContentLength: int64(len(content)),
+		Request:       req,
+	}, nil
+}
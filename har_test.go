@@ -0,0 +1,154 @@
+package direwolf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testGzipBody is "hello" compressed by gzip.
+var testGzipBody = func() []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Write([]byte("hello"))
+	writer.Close()
+	return buf.Bytes()
+}()
+
+func newTestHARServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "token", Value: "direwolf", Path: "/"})
+			http.Redirect(w, r, "/home", 302)
+		case "/home":
+			w.Write([]byte("welcome"))
+		case "/echo":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(append(body, 0xff))
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(testGzipBody)
+		}
+	}))
+}
+
+func TestHARRecorder(t *testing.T) {
+	ts := newTestHARServer()
+	defer ts.Close()
+
+	session := NewSession()
+	recorder := NewHARRecorder()
+	session.WrapTransport(recorder.Wrap)
+	if _, err := session.Get(ts.URL + "/login"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := session.Post(ts.URL+"/echo?a=1", Body("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Content) != "hello\xff" {
+		t.Fatal("HARRecorder changed the response.")
+	}
+
+	har := recorder.HAR()
+	entries := har.Log.Entries
+	if har.Log.Version != "1.2" || len(entries) != 3 {
+		t.Fatal("HARRecorder failed: ", len(entries))
+	}
+	login, home, echo := entries[0], entries[1], entries[2]
+	if login.Response.Status != 302 || login.Response.RedirectURL != "/home" || login.Response.Cookies[0].Value != "direwolf" {
+		t.Fatal("HARRecorder redirect failed.")
+	}
+	if home.Request.Cookies[0].Name != "token" || home.Response.Content.Text != "welcome" {
+		t.Fatal("HARRecorder cookies failed.")
+	}
+	if login.Timings.Connect < 0 || home.Timings.Connect != -1 || home.Timings.Wait < 0 || login.ServerIPAddress != "127.0.0.1" {
+		t.Fatal("HARRecorder timings failed: ", login.Timings, home.Timings)
+	}
+	if echo.Request.PostData.Text != "hello" || echo.Request.QueryString[0].Value != "1" {
+		t.Fatal("HARRecorder request failed.")
+	}
+	if echo.Response.Content.Encoding != "base64" || echo.Response.Content.Size != 6 {
+		t.Fatal("HARRecorder binary content failed.")
+	}
+
+	// save and load the HAR file.
+	dir, err := ioutil.TempDir("", "direwolf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.har")
+	if err := har.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadHAR(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Log.Entries) != 3 || loaded.Log.Entries[2].Response.Content.Text != echo.Response.Content.Text {
+		t.Fatal("ReadHAR failed.")
+	}
+
+	// replay the entries as live requests.
+	responses, err := ReplayHAR(NewSession(), loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 3 || responses[0].StatusCode != 302 || responses[1].Text() != "welcome" ||
+		string(responses[2].Content) != "hello\xff" {
+		t.Fatal("ReplayHAR failed.")
+	}
+}
+
+func TestHARTransport(t *testing.T) {
+	ts := newTestHARServer()
+	recorder := NewHARRecorder()
+	session := NewSession()
+	session.WrapTransport(recorder.Wrap)
+	if _, err := session.Get(ts.URL + "/login"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Post(ts.URL+"/echo", Body("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Get(ts.URL+"/gzip", NewHeaders("Accept-Encoding", "gzip")); err != nil {
+		t.Fatal(err)
+	}
+	ts.Close() // the server is not needed anymore.
+
+	stub := NewSession(&SessionOptions{Transport: NewHARTransport(recorder.HAR())})
+	resp, err := stub.Get(ts.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "welcome" || len(resp.History) != 1 || stub.Cookies(ts.URL)[0].Value != "direwolf" {
+		t.Fatal("HARTransport failed.")
+	}
+	resp, err = stub.Post(ts.URL + "/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Content) != "hello\xff" {
+		t.Fatal("HARTransport binary content failed.")
+	}
+	// the body is not decoded by the transport, so it is still encoded.
+	resp, err = stub.Get(ts.URL+"/gzip", NewHeaders("Accept-Encoding", "gzip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Headers.Get("Content-Encoding") != "gzip" || !bytes.Equal(resp.Content, testGzipBody) {
+		t.Fatal("HARTransport Content-Encoding failed.")
+	}
+	if _, err := stub.Get(ts.URL + "/missing"); !errors.Is(err, ErrHARNotFound) {
+		t.Fatal("HARTransport not found failed: ", err)
+	}
+}
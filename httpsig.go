@@ -0,0 +1,263 @@
+package direwolf
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The algorithms of HTTP Message Signatures.
+const (
+	SigHMACSHA256   = "hmac-sha256"
+	SigEd25519      = "ed25519"
+	SigRSAPSSSHA512 = "rsa-pss-sha512"
+)
+
+// HTTPSignature signs requests with HTTP Message Signatures (RFC 9421), one of
+// the Request Options. It can be set as the Session default by Session.With.
+// Please use NewHMACSignature, NewEd25519Signature or NewRSAPSSSignature to
+// new it.
+//
+// Components are the covered components, which are derived components like
+// "@method", "@authority", "@path", "@query", "@target-uri", or lowercase
+// header names like "content-type". If "content-digest" is covered, the
+// Content-Digest header (RFC 9530) of the body is set before signing.
+type HTTPSignature struct {
+	KeyID      string
+	Algorithm  string
+	Key        interface{} // []byte for HMAC, crypto.Signer for Ed25519 and RSA-PSS.
+	Components []string
+
+	// Label is the label of the signature, default is "sig1".
+	Label string
+
+	// DigestAlgorithm is the algorithm of Content-Digest, "sha-256" or "sha-512",
+	// default is "sha-256".
+	DigestAlgorithm string
+
+	// Expires is added to the signature parameters if it is not zero.
+	Expires time.Duration
+
+	// Tag is added to the signature parameters if it is not empty.
+	Tag string
+
+	// Now returns the signing time, default is time.Now.
+	Now func() time.Time
+}
+
+// NewHMACSignature new a HTTPSignature with HMAC-SHA256.
+func NewHMACSignature(keyID string, key []byte, components ...string) *HTTPSignature {
+	return &HTTPSignature{KeyID: keyID, Algorithm: SigHMACSHA256, Key: key, Components: components}
+}
+
+// NewEd25519Signature new a HTTPSignature with Ed25519. The key is usually
+// ed25519.PrivateKey.
+func NewEd25519Signature(keyID string, key crypto.Signer, components ...string) *HTTPSignature {
+	return &HTTPSignature{KeyID: keyID, Algorithm: SigEd25519, Key: key, Components: components}
+}
+
+// NewRSAPSSSignature new a HTTPSignature with RSA-PSS using SHA-512.
+func NewRSAPSSSignature(keyID string, key *rsa.PrivateKey, components ...string) *HTTPSignature {
+	return &HTTPSignature{KeyID: keyID, Algorithm: SigRSAPSSSHA512, Key: key, Components: components}
+}
+
+// RequestOption interface method, bind request option to request.
+func (options *HTTPSignature) bindRequest(request *Request) error {
+	request.Auth = options
+	return nil
+}
+
+func (options *HTTPSignature) newAuthorizer() authorizer {
+	return options
+}
+
+func (options *HTTPSignature) authorize(req *http.Request) error {
+	now := time.Now()
+	if options.Now != nil {
+		now = options.Now()
+	}
+	return options.sign(req, now)
+}
+
+func (options *HTTPSignature) challenge(resp *Response) (bool, error) {
+	return false, nil
+}
+
+// sign sets the Signature, Signature-Input and Content-Digest headers.
+func (options *HTTPSignature) sign(req *http.Request, now time.Time) error {
+	for _, component := range options.Components {
+		if component == "content-digest" {
+			if err := options.setContentDigest(req); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	params := options.signatureParams(now)
+	base, err := signatureBase(req, options.Components, params)
+	if err != nil {
+		return err
+	}
+	signature, err := options.signBase([]byte(base))
+	if err != nil {
+		return err
+	}
+
+	label := options.Label
+	if label == "" {
+		label = "sig1"
+	}
+	req.Header.Set("Signature-Input", label+"="+params)
+	req.Header.Set("Signature", label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+// signatureParams returns the serialized signature parameters.
+func (options *HTTPSignature) signatureParams(now time.Time) string {
+	quoted := make([]string, len(options.Components))
+	for i, component := range options.Components {
+		quoted[i] = strconv.Quote(component)
+	}
+	params := "(" + strings.Join(quoted, " ") + ");created=" + strconv.FormatInt(now.Unix(), 10)
+	if options.Expires > 0 {
+		params += ";expires=" + strconv.FormatInt(now.Add(options.Expires).Unix(), 10)
+	}
+	params += ";keyid=" + strconv.Quote(options.KeyID)
+	if options.Tag != "" {
+		params += ";tag=" + strconv.Quote(options.Tag)
+	}
+	return params
+}
+
+// signBase signs the signature base with the key.
+func (options *HTTPSignature) signBase(base []byte) ([]byte, error) {
+	switch options.Algorithm {
+	case SigHMACSHA256:
+		key, ok := options.Key.([]byte)
+		if !ok {
+			return nil, WrapErr(ErrSignature, "HMAC key must be []byte")
+		}
+		return hmacSHA256(key, base), nil
+	case SigEd25519:
+		signer, ok := options.Key.(crypto.Signer)
+		if !ok {
+			return nil, WrapErr(ErrSignature, "Ed25519 key must be crypto.Signer")
+		}
+		return signer.Sign(rand.Reader, base, crypto.Hash(0))
+	case SigRSAPSSSHA512:
+		signer, ok := options.Key.(crypto.Signer)
+		if !ok {
+			return nil, WrapErr(ErrSignature, "RSA-PSS key must be crypto.Signer")
+		}
+		digest := sha512.Sum512(base)
+		return signer.Sign(rand.Reader, digest[:], &rsa.PSSOptions{SaltLength: 64, Hash: crypto.SHA512})
+	default:
+		return nil, WrapErrf(ErrSignature, "unsupported algorithm: %s", options.Algorithm)
+	}
+}
+
+// setContentDigest sets the Content-Digest header of the request body.
+func (options *HTTPSignature) setContentDigest(req *http.Request) error {
+	var h hash.Hash
+	algorithm := options.DigestAlgorithm
+	switch algorithm {
+	case "", "sha-256":
+		algorithm, h = "sha-256", sha256.New()
+	case "sha-512":
+		h = sha512.New()
+	default:
+		return WrapErrf(ErrSignature, "unsupported digest algorithm: %s", algorithm)
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return WrapErr(err, "read request body failed")
+		}
+		defer body.Close()
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return WrapErr(err, "read request body failed")
+		}
+		h.Write(data)
+	}
+	req.Header.Set("Content-Digest", algorithm+"=:"+base64.StdEncoding.EncodeToString(h.Sum(nil))+":")
+	return nil
+}
+
+// signatureBase build the signature base of the covered components.
+func signatureBase(req *http.Request, components []string, params string) (string, error) {
+	var b strings.Builder
+	for _, component := range components {
+		value, err := componentValue(req, component)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(strconv.Quote(component) + ": " + value + "\n")
+	}
+	b.WriteString(`"@signature-params": ` + params)
+	return b.String(), nil
+}
+
+// componentValue returns the value of the covered component.
+func componentValue(req *http.Request, component string) (string, error) {
+	switch component {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		return req.URL.String(), nil
+	case "@authority":
+		return signatureAuthority(req), nil
+	case "@scheme":
+		return strings.ToLower(req.URL.Scheme), nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		if path := req.URL.EscapedPath(); path != "" {
+			return path, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	case "content-length":
+		if req.ContentLength > 0 {
+			return strconv.FormatInt(req.ContentLength, 10), nil
+		}
+	}
+	if strings.HasPrefix(component, "@") {
+		return "", WrapErrf(ErrSignature, "unsupported component: %s", component)
+	}
+	values, ok := req.Header[http.CanonicalHeaderKey(component)]
+	if !ok {
+		return "", WrapErrf(ErrSignature, "missing header: %s", component)
+	}
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ", "), nil
+}
+
+// signatureAuthority returns the lowercase host, the default port is removed.
+func signatureAuthority(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host = strings.ToLower(host)
+	if req.URL.Scheme == "http" {
+		host = strings.TrimSuffix(host, ":80")
+	} else if req.URL.Scheme == "https" {
+		host = strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
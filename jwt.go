@@ -0,0 +1,161 @@
+package direwolf
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWTAuth is the bearer token authentication with JWT, one of the Request
+// Options. Set it as the Session default by Session.With, so that the token
+// is shared by all requests of the Session. Please use NewJWTAuth to new it.
+//
+// The exp claim of the JWT is decoded without verifying the signature. The
+// token is refreshed by Refresh ExpiryDelta before it expires, concurrent
+// requests wait for the same refresh. If the server returns 401, the token is
+// refreshed and the request is sent again once.
+type JWTAuth struct {
+	// Refresh returns a new token, it is called when the token is about to
+	// expire. If it is nil, the token can not be refreshed.
+	Refresh func() (string, error)
+
+	// ExpiryDelta is how early a token should be refreshed before it
+	// expires, default is 10 seconds.
+	ExpiryDelta time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time // zero means the token never expires
+}
+
+// NewJWTAuth new a JWTAuth. The token can be empty, then it will be fetched
+// by refresh before the first request.
+func NewJWTAuth(token string, refresh func() (string, error)) (*JWTAuth, error) {
+	auth := &JWTAuth{Refresh: refresh}
+	if token != "" {
+		if err := auth.SetToken(token); err != nil {
+			return nil, err
+		}
+	}
+	return auth, nil
+}
+
+// RequestOption interface method, bind request option to request.
+func (options *JWTAuth) bindRequest(request *Request) error {
+	request.Auth = options
+	return nil
+}
+
+func (options *JWTAuth) newAuthorizer() authorizer {
+	return &jwtAuthorizer{auth: options}
+}
+
+// Token returns a valid token, it will be refreshed if needed.
+func (options *JWTAuth) Token() (string, error) {
+	options.mu.Lock()
+	defer options.mu.Unlock()
+
+	delta := options.ExpiryDelta
+	if delta == 0 {
+		delta = defaultExpiryDelta
+	}
+	if options.token != "" && (options.expiry.IsZero() || time.Now().Add(delta).Before(options.expiry)) {
+		return options.token, nil
+	}
+	if options.Refresh == nil {
+		if options.token == "" {
+			return "", WrapErr(ErrJWT, "no token")
+		}
+		return "", WrapErr(ErrJWT, "token is expired")
+	}
+	token, err := options.Refresh()
+	if err != nil {
+		return "", WrapErr(err, "refresh JWT failed")
+	}
+	if err := options.setToken(token); err != nil {
+		return "", err
+	}
+	return options.token, nil
+}
+
+// SetToken sets the token, its expiry is decoded from the exp claim.
+func (options *JWTAuth) SetToken(token string) error {
+	options.mu.Lock()
+	defer options.mu.Unlock()
+	return options.setToken(token)
+}
+
+// setToken sets the token, it must be called with the lock.
+func (options *JWTAuth) setToken(token string) error {
+	expiry, err := jwtExpiry(token)
+	if err != nil {
+		return err
+	}
+	options.token, options.expiry = token, expiry
+	return nil
+}
+
+// invalidate marks the token is expired, if it is still the current token.
+func (options *JWTAuth) invalidate(token string) {
+	options.mu.Lock()
+	defer options.mu.Unlock()
+	if options.token == token { // otherwise it has been refreshed by others.
+		options.token = ""
+	}
+}
+
+// jwtExpiry decodes the exp claim of the JWT without verifying it.
+// It returns zero time if there is no exp claim.
+func jwtExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, WrapErr(ErrJWT, "token must have 3 parts")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, WrapErr(ErrJWT, "decode payload failed")
+	}
+	var claims struct {
+		Exp *json.Number `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, WrapErr(ErrJWT, "decode claims failed")
+	}
+	if claims.Exp == nil {
+		return time.Time{}, nil
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, WrapErr(ErrJWT, "invalid exp claim")
+	}
+	return time.Unix(int64(exp), 0), nil
+}
+
+// jwtAuthorizer authenticates a Request with JWTAuth.
+type jwtAuthorizer struct {
+	auth    *JWTAuth
+	token   string
+	retried bool
+}
+
+func (j *jwtAuthorizer) authorize(req *http.Request) error {
+	token, err := j.auth.Token()
+	if err != nil {
+		return err
+	}
+	j.token = token
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (j *jwtAuthorizer) challenge(resp *Response) (bool, error) {
+	if j.retried || j.token == "" || j.auth.Refresh == nil {
+		return false, nil
+	}
+	j.retried = true
+	j.auth.invalidate(j.token)
+	return true, nil
+}
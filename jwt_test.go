@@ -0,0 +1,100 @@
+package direwolf
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestJWT returns an unsigned JWT with the exp claim.
+func newTestJWT(id int, exp time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"` + strconv.Itoa(id) + `","exp":` + strconv.FormatInt(exp.Unix(), 10) + `}`))
+	return header + "." + payload + ".signature"
+}
+
+func TestJWTExpiry(t *testing.T) {
+	exp := time.Unix(1700000000, 0)
+	expiry, err := jwtExpiry(newTestJWT(1, exp))
+	if err != nil || !expiry.Equal(exp) {
+		t.Fatal("jwtExpiry failed: ", expiry, err)
+	}
+	expiry, err = jwtExpiry("eyJhbGciOiJub25lIn0.eyJzdWIiOiIxIn0.")
+	if err != nil || !expiry.IsZero() {
+		t.Fatal("jwtExpiry without exp failed.")
+	}
+	if _, err := jwtExpiry("token"); !errors.Is(err, ErrJWT) {
+		t.Fatal("jwtExpiry invalid token failed.")
+	}
+}
+
+func TestJWTAuth(t *testing.T) {
+	var mu sync.Mutex
+	var validToken string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer "+validToken {
+			w.WriteHeader(401)
+		}
+	}))
+	defer ts.Close()
+
+	refreshed := 0
+	refresh := func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		refreshed++
+		validToken = newTestJWT(refreshed, time.Now().Add(time.Hour))
+		return validToken, nil
+	}
+	auth, err := NewJWTAuth(newTestJWT(0, time.Now().Add(time.Second)), refresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := NewSession().With(auth)
+
+	// the token is about to expire, it is refreshed once for concurrent requests.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := session.Get(ts.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if resp.StatusCode != 200 {
+				t.Error("JWTAuth failed.")
+			}
+		}()
+	}
+	wg.Wait()
+	if refreshed != 1 {
+		t.Fatal("JWTAuth refresh failed: ", refreshed)
+	}
+
+	// the token is revoked by the server, it is refreshed after 401.
+	mu.Lock()
+	validToken = "revoked"
+	mu.Unlock()
+	resp, err := session.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || refreshed != 2 {
+		t.Fatal("JWTAuth retry failed.")
+	}
+
+	// the expired token can not be used without Refresh.
+	auth, _ = NewJWTAuth(newTestJWT(0, time.Now().Add(-time.Hour)), nil)
+	if _, err := Get(ts.URL, auth); !errors.Is(err, ErrJWT) {
+		t.Fatal("JWTAuth expired failed: ", err)
+	}
+}
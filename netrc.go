@@ -0,0 +1,182 @@
+package direwolf
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Netrc is the parsed .netrc file, which stores the credentials of machines.
+type Netrc struct {
+	Machines []*NetrcMachine
+	Default  *NetrcMachine     // the default entry, nil if there is no one
+	Macros   map[string]string // macdef entries, by name
+}
+
+// NetrcMachine is a machine entry of .netrc file.
+type NetrcMachine struct {
+	Name     string
+	Login    string
+	Password string
+	Account  string
+}
+
+// Machine returns the entry of the host, or the default entry if there is no
+// matching machine. It returns nil if there is neither.
+func (netrc *Netrc) Machine(host string) *NetrcMachine {
+	for _, machine := range netrc.Machines {
+		if strings.EqualFold(machine.Name, host) {
+			return machine
+		}
+	}
+	return netrc.Default
+}
+
+// DefaultNetrcPath returns the path of the .netrc file. It is $NETRC if it is
+// set, otherwise ~/.netrc, or ~/_netrc on Windows.
+func DefaultNetrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc")
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// ReadNetrc reads and parses the .netrc file.
+func ReadNetrc(path string) (*Netrc, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, WrapErr(err, "open netrc file failed")
+	}
+	defer f.Close()
+	return ParseNetrc(f)
+}
+
+// ParseNetrc parses the .netrc content. It supports machine, default, login,
+// password, account and macdef entries, the tokens can be quoted by double
+// quotes. Lines start with '#' are comments.
+func ParseNetrc(r io.Reader) (*Netrc, error) {
+	netrc := &Netrc{Macros: make(map[string]string)}
+	var machine *NetrcMachine
+	var macro string // name of the macro being read
+	var inMacro bool // whether reading the body of macdef
+	var macroBody []string
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+
+		// the body of macdef ends with an empty line.
+		if inMacro {
+			if strings.TrimSpace(line) == "" {
+				netrc.Macros[macro] = strings.Join(macroBody, "\n")
+				inMacro, macroBody = false, nil
+			} else {
+				macroBody = append(macroBody, line)
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		tokens, err := netrcTokens(line)
+		if err != nil {
+			return nil, WrapErrf(err, "netrc line %d", lineNum)
+		}
+		for i := 0; i < len(tokens); i++ {
+			keyword := tokens[i]
+			switch keyword {
+			case "default":
+				machine = &NetrcMachine{}
+				netrc.Default = machine
+				continue
+			case "macdef":
+				if i+1 >= len(tokens) {
+					return nil, WrapErrf(ErrNetrc, "line %d: missing macdef name", lineNum)
+				}
+				macro, inMacro = tokens[i+1], true
+				i = len(tokens) // the rest of the line is ignored.
+				continue
+			}
+			if i+1 >= len(tokens) {
+				return nil, WrapErrf(ErrNetrc, "line %d: missing value of %s", lineNum, keyword)
+			}
+			value := tokens[i+1]
+			i++
+			if keyword == "machine" {
+				machine = &NetrcMachine{Name: value}
+				netrc.Machines = append(netrc.Machines, machine)
+				continue
+			}
+			if machine == nil {
+				return nil, WrapErrf(ErrNetrc, "line %d: %s is outside of machine", lineNum, keyword)
+			}
+			switch keyword {
+			case "login":
+				machine.Login = value
+			case "password":
+				machine.Password = value
+			case "account":
+				machine.Account = value
+			default:
+				return nil, WrapErrf(ErrNetrc, "line %d: unknown token %s", lineNum, keyword)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, WrapErr(err, "read netrc failed")
+	}
+	if inMacro {
+		netrc.Macros[macro] = strings.Join(macroBody, "\n")
+	}
+	return netrc, nil
+}
+
+// netrcTokens splits the line into tokens, the double quoted token can have
+// spaces and escaped characters.
+func netrcTokens(line string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(line); {
+		c := line[i]
+		if c == ' ' || c == '\t' || c == '\r' {
+			i++
+			continue
+		}
+		var token strings.Builder
+		if c == '"' {
+			i++
+			closed := false
+			for ; i < len(line); i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+					token.WriteByte(line[i])
+				} else if line[i] == '"' {
+					closed = true
+					i++
+					break
+				} else {
+					token.WriteByte(line[i])
+				}
+			}
+			if !closed {
+				return nil, WrapErr(ErrNetrc, "unterminated quote")
+			}
+		} else {
+			for ; i < len(line) && line[i] != ' ' && line[i] != '\t' && line[i] != '\r'; i++ {
+				token.WriteByte(line[i])
+			}
+		}
+		tokens = append(tokens, token.String())
+	}
+	return tokens, nil
+}
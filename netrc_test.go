@@ -0,0 +1,121 @@
+package direwolf
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testNetrc = `# credentials
+machine example.com login alice password "secret word"
+machine api.example.com
+	login bob
+	password p\ass
+	account dev
+
+macdef init
+cd /pub
+binary
+
+default login anonymous password guest
+`
+
+func TestParseNetrc(t *testing.T) {
+	netrc, err := ParseNetrc(strings.NewReader(testNetrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(netrc.Machines) != 2 {
+		t.Fatal("ParseNetrc failed.")
+	}
+	m := netrc.Machine("EXAMPLE.com")
+	if m.Login != "alice" || m.Password != "secret word" {
+		t.Fatal("ParseNetrc quoted token failed: ", m)
+	}
+	m = netrc.Machine("api.example.com")
+	if m.Login != "bob" || m.Password != `p\ass` || m.Account != "dev" {
+		t.Fatal("ParseNetrc multi-line machine failed: ", m)
+	}
+	if netrc.Macros["init"] != "cd /pub\nbinary" {
+		t.Fatal("ParseNetrc macdef failed: ", netrc.Macros)
+	}
+	m = netrc.Machine("other.com")
+	if m == nil || m.Login != "anonymous" || m.Password != "guest" {
+		t.Fatal("ParseNetrc default failed.")
+	}
+
+	for _, content := range []string{"machine", "login alice", "machine a unknown b", `machine "a`} {
+		if _, err := ParseNetrc(strings.NewReader(content)); !errors.Is(err, ErrNetrc) {
+			t.Fatal("ParseNetrc invalid content failed: ", content)
+		}
+	}
+}
+
+func TestSessionNetrc(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		w.Write([]byte(username + ":" + password))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "direwolf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ".netrc")
+	if err := ioutil.WriteFile(path, []byte("machine 127.0.0.1 login alice password secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultSessionOptions()
+	options.Netrc = true
+	options.NetrcPath = path
+	session := NewSession(options)
+
+	resp, err := session.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "alice:secret" {
+		t.Fatal("Session netrc failed: ", resp.Text())
+	}
+
+	// Auth of request has higher priority.
+	resp, err = session.Get(ts.URL, &BasicAuth{Username: "bob", Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "bob:pass" {
+		t.Fatal("Session netrc failed: ", resp.Text())
+	}
+
+	// Authorization header of session has higher priority.
+	session.SetHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("carol:word")))
+	resp, err = session.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "carol:word" {
+		t.Fatal("Session netrc failed: ", resp.Text())
+	}
+
+	// the missing file is ignored.
+	options.NetrcPath = filepath.Join(dir, "missing")
+	session = NewSession(options)
+	if session == nil {
+		t.Fatal("Session netrc missing file failed.")
+	}
+	resp, err = session.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != ":" {
+		t.Fatal("Session netrc failed: ", resp.Text())
+	}
+}
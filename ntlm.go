@@ -0,0 +1,234 @@
+package direwolf
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// NTLMAuth is the NTLM authentication with NTLMv2 response, one of the
+// Request Options.
+//
+// NTLM authenticates the connection instead of the request, so please keep
+// the connections alive (it is by default), the handshake requests are sent
+// on the same idle connection.
+type NTLMAuth struct {
+	Domain      string
+	Username    string
+	Password    string
+	Workstation string
+}
+
+// RequestOption interface method, bind request option to request.
+func (options *NTLMAuth) bindRequest(request *Request) error {
+	request.Auth = options
+	return nil
+}
+
+func (options *NTLMAuth) newAuthorizer() authorizer {
+	return &ntlmAuthorizer{auth: options}
+}
+
+const (
+	ntlmNegotiateUnicode          = 0x00000001
+	ntlmNegotiateOEM              = 0x00000002
+	ntlmRequestTarget             = 0x00000004
+	ntlmNegotiateNTLM             = 0x00000200
+	ntlmNegotiateAlwaysSign       = 0x00008000
+	ntlmNegotiateExtendedSecurity = 0x00080000
+	ntlmNegotiateTargetInfo       = 0x00800000
+
+	ntlmNegotiateFlags = ntlmNegotiateUnicode | ntlmNegotiateOEM | ntlmRequestTarget |
+		ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSecurity
+
+	ntlmAvTimestamp = 7 // MsvAvTimestamp in target info
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmAuthorizer authenticates a Request with NTLMAuth. It sends the
+// negotiate message first, then the authenticate message after the
+// server returns the challenge message.
+type ntlmAuthorizer struct {
+	auth             *NTLMAuth
+	challengeMessage []byte // challenge message from server
+	done             bool
+}
+
+func (n *ntlmAuthorizer) authorize(req *http.Request) error {
+	var message []byte
+	if n.challengeMessage == nil {
+		message = ntlmNegotiateMessage()
+	} else {
+		var err error
+		message, err = ntlmAuthenticateMessage(n.auth, n.challengeMessage)
+		if err != nil {
+			return err
+		}
+		n.done = true
+	}
+	req.Header.Set("Authorization", "NTLM "+base64.StdEncoding.EncodeToString(message))
+	return nil
+}
+
+func (n *ntlmAuthorizer) challenge(resp *Response) (bool, error) {
+	if n.done { // the authenticate message is rejected.
+		return false, nil
+	}
+	for _, header := range resp.Headers.Values("Www-Authenticate") {
+		if !strings.HasPrefix(header, "NTLM ") {
+			continue
+		}
+		message, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len("NTLM "):]))
+		if err != nil {
+			return false, WrapErr(ErrAuthChallenge, "decode NTLM challenge message failed")
+		}
+		n.challengeMessage = message
+		return true, nil
+	}
+	return false, nil
+}
+
+// ntlmNegotiateMessage build the NTLM negotiate message (type 1).
+func ntlmNegotiateMessage() []byte {
+	message := make([]byte, 32)
+	copy(message, ntlmSignature)
+	binary.LittleEndian.PutUint32(message[8:], 1)
+	binary.LittleEndian.PutUint32(message[12:], ntlmNegotiateFlags)
+	// domain and workstation fields are empty, offsets point to the end.
+	binary.LittleEndian.PutUint32(message[20:], 32)
+	binary.LittleEndian.PutUint32(message[28:], 32)
+	return message
+}
+
+// ntlmChallenge is the parsed NTLM challenge message (type 2).
+type ntlmChallenge struct {
+	flags           uint32
+	serverChallenge []byte
+	targetInfo      []byte
+}
+
+// parseNTLMChallenge parse the NTLM challenge message (type 2).
+func parseNTLMChallenge(message []byte) (*ntlmChallenge, error) {
+	if len(message) < 32 || !bytes.Equal(message[:8], ntlmSignature) || binary.LittleEndian.Uint32(message[8:]) != 2 {
+		return nil, WrapErr(ErrAuthChallenge, "invalid NTLM challenge message")
+	}
+	c := &ntlmChallenge{
+		flags:           binary.LittleEndian.Uint32(message[20:]),
+		serverChallenge: message[24:32],
+	}
+	if len(message) >= 48 {
+		length := int(binary.LittleEndian.Uint16(message[40:]))
+		offset := int(binary.LittleEndian.Uint32(message[44:]))
+		if offset+length > len(message) {
+			return nil, WrapErr(ErrAuthChallenge, "invalid NTLM target info")
+		}
+		c.targetInfo = message[offset : offset+length]
+	}
+	return c, nil
+}
+
+// ntlmAuthenticateMessage build the NTLM authenticate message (type 3) with
+// NTLMv2 response.
+func ntlmAuthenticateMessage(auth *NTLMAuth, challengeMessage []byte) ([]byte, error) {
+	c, err := parseNTLMChallenge(challengeMessage)
+	if err != nil {
+		return nil, err
+	}
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, WrapErr(err, "generate client challenge failed")
+	}
+	timestamp := ntlmTimestamp(c.targetInfo)
+	ntResponse, lmResponse := ntlmV2Response(auth, c.serverChallenge, clientChallenge, timestamp, c.targetInfo)
+
+	encode := func(s string) []byte {
+		if c.flags&ntlmNegotiateUnicode != 0 {
+			return utf16LE(s)
+		}
+		return []byte(strings.ToUpper(s))
+	}
+	fields := [][]byte{lmResponse, ntResponse, encode(auth.Domain), encode(auth.Username), encode(auth.Workstation), nil}
+
+	const headerSize = 64
+	message := make([]byte, headerSize)
+	copy(message, ntlmSignature)
+	binary.LittleEndian.PutUint32(message[8:], 3)
+	offset := headerSize
+	for i, field := range fields {
+		pos := 12 + i*8
+		binary.LittleEndian.PutUint16(message[pos:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(message[pos+2:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(message[pos+4:], uint32(offset))
+		message = append(message, field...)
+		offset += len(field)
+	}
+	flags := ntlmNegotiateFlags&^ntlmNegotiateUnicode | c.flags&ntlmNegotiateUnicode
+	binary.LittleEndian.PutUint32(message[60:], flags)
+	return message, nil
+}
+
+// ntlmV2Response computes the NTLMv2 and LMv2 response.
+func ntlmV2Response(auth *NTLMAuth, serverChallenge, clientChallenge, timestamp, targetInfo []byte) (ntResponse, lmResponse []byte) {
+	ntHash := md4.New()
+	ntHash.Write(utf16LE(auth.Password))
+	ntowfv2 := hmacMD5(ntHash.Sum(nil), utf16LE(strings.ToUpper(auth.Username)+auth.Domain))
+
+	temp := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+
+	ntProof := hmacMD5(ntowfv2, append(append([]byte{}, serverChallenge...), temp...))
+	ntResponse = append(ntProof, temp...)
+	lmResponse = append(hmacMD5(ntowfv2, append(append([]byte{}, serverChallenge...), clientChallenge...)), clientChallenge...)
+	return ntResponse, lmResponse
+}
+
+// ntlmTimestamp returns the timestamp in target info, or the current time,
+// in windows FILETIME format.
+func ntlmTimestamp(targetInfo []byte) []byte {
+	for i := 0; i+4 <= len(targetInfo); {
+		id := binary.LittleEndian.Uint16(targetInfo[i:])
+		length := int(binary.LittleEndian.Uint16(targetInfo[i+2:]))
+		if id == ntlmAvTimestamp && length == 8 && i+12 <= len(targetInfo) {
+			return append([]byte{}, targetInfo[i+4:i+12]...)
+		}
+		if id == 0 { // MsvAvEOL
+			break
+		}
+		i += 4 + length
+	}
+	timestamp := make([]byte, 8)
+	// FILETIME is the number of 100 nanoseconds since January 1, 1601.
+	binary.LittleEndian.PutUint64(timestamp, uint64(time.Now().UnixNano()/100+116444736000000000))
+	return timestamp
+}
+
+// hmacMD5 computes the HMAC-MD5 of data.
+func hmacMD5(key, data []byte) []byte {
+	mac := hmac.New(md5.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// utf16LE encodes the string to UTF-16 little endian.
+func utf16LE(s string) []byte {
+	codes := utf16.Encode([]rune(s))
+	b := make([]byte, len(codes)*2)
+	for i, code := range codes {
+		binary.LittleEndian.PutUint16(b[i*2:], code)
+	}
+	return b
+}
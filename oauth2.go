@@ -0,0 +1,293 @@
+package direwolf
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultExpiryDelta is how early a token should be refreshed before it expires.
+const defaultExpiryDelta = 10 * time.Second
+
+// Token is the OAuth2 token.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"` // zero means the token never expires
+}
+
+// valid check whether the token can be used, it is invalid delta before it expires.
+func (t *Token) valid(delta time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(delta).Before(t.Expiry)
+}
+
+// authorization returns the value of Authorization header.
+func (t *Token) authorization() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer " + t.AccessToken
+	}
+	return t.TokenType + " " + t.AccessToken
+}
+
+// TokenStore is the storage of OAuth2 token, such as memory, file or redis.
+// It must be safe for concurrent use.
+type TokenStore interface {
+	// Load returns the stored token, returns nil if there is no token.
+	Load() (*Token, error)
+	// Save stores the token.
+	Save(token *Token) error
+}
+
+// MemoryTokenStore stores the token in memory. It is the default TokenStore.
+type MemoryTokenStore struct {
+	mu    sync.RWMutex
+	token *Token
+}
+
+// Load returns the stored token.
+func (store *MemoryTokenStore) Load() (*Token, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return store.token, nil
+}
+
+// Save stores the token.
+func (store *MemoryTokenStore) Save(token *Token) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.token = token
+	return nil
+}
+
+// OAuth2 is the OAuth2 authentication, one of the Request Options. It supports
+// client credentials and refresh token flows. Set it as the Session default
+// by Session.With, so that the token is shared by all requests of the Session.
+//
+// It fetches the token from TokenURL, caches it in Store until it expires, and
+// refreshes it ExpiryDelta before expiry. If the server returns 401, the token
+// is refreshed and the request is sent again once.
+type OAuth2 struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// EndpointParams are the extra parameters of token requests.
+	EndpointParams *PostForm
+
+	// ClientAuthInBody, if true, sends client id and secret in the request
+	// body instead of HTTP Basic authentication.
+	ClientAuthInBody bool
+
+	// Store is the token storage, default is a MemoryTokenStore.
+	Store TokenStore
+
+	// Session is used to send the token requests, default is DefaultSession().
+	// It must not use this OAuth2 as the default Auth.
+	Session *Session
+
+	// ExpiryDelta is how early a token should be refreshed before it
+	// expires, default is 10 seconds.
+	ExpiryDelta time.Duration
+
+	mu sync.Mutex
+}
+
+// NewOAuth2ClientCredentials new an OAuth2 with client credentials flow.
+func NewOAuth2ClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) *OAuth2 {
+	return &OAuth2{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		Store:        &MemoryTokenStore{},
+	}
+}
+
+// NewOAuth2RefreshToken new an OAuth2 with refresh token flow, the access
+// token will be fetched by the refresh token.
+func NewOAuth2RefreshToken(tokenURL, clientID, clientSecret, refreshToken string) *OAuth2 {
+	store := &MemoryTokenStore{}
+	store.Save(&Token{RefreshToken: refreshToken})
+	return &OAuth2{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Store:        store,
+	}
+}
+
+// RequestOption interface method, bind request option to request.
+func (options *OAuth2) bindRequest(request *Request) error {
+	request.Auth = options
+	return nil
+}
+
+func (options *OAuth2) newAuthorizer() authorizer {
+	return &oauth2Authorizer{auth: options}
+}
+
+// Token returns a valid token, it will be fetched or refreshed if needed.
+func (options *OAuth2) Token() (*Token, error) {
+	options.mu.Lock()
+	defer options.mu.Unlock()
+
+	store := options.store()
+	token, err := store.Load()
+	if err != nil {
+		return nil, WrapErr(err, "load oauth2 token failed")
+	}
+	delta := options.ExpiryDelta
+	if delta == 0 {
+		delta = defaultExpiryDelta
+	}
+	if token.valid(delta) {
+		return token, nil
+	}
+
+	form := NewPostForm()
+	if token != nil && token.RefreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", token.RefreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	newToken, err := options.requestToken(form)
+	if err != nil {
+		return nil, err
+	}
+	if newToken.RefreshToken == "" && token != nil { // keep the refresh token if the server does not return a new one.
+		newToken.RefreshToken = token.RefreshToken
+	}
+	if err := store.Save(newToken); err != nil {
+		return nil, WrapErr(err, "save oauth2 token failed")
+	}
+	return newToken, nil
+}
+
+// SetToken stores the token, such as the token from authorization code flow.
+func (options *OAuth2) SetToken(token *Token) error {
+	options.mu.Lock()
+	defer options.mu.Unlock()
+	return options.store().Save(token)
+}
+
+// invalidate marks the token is expired, if it is still the stored token.
+func (options *OAuth2) invalidate(token *Token) error {
+	options.mu.Lock()
+	defer options.mu.Unlock()
+	store := options.store()
+	current, err := store.Load()
+	if err != nil {
+		return WrapErr(err, "load oauth2 token failed")
+	}
+	if current == nil || current.AccessToken != token.AccessToken { // has been refreshed by others.
+		return nil
+	}
+	return store.Save(&Token{RefreshToken: current.RefreshToken})
+}
+
+// store returns the TokenStore, it must be called with the lock.
+func (options *OAuth2) store() TokenStore {
+	if options.Store == nil {
+		options.Store = &MemoryTokenStore{}
+	}
+	return options.Store
+}
+
+// tokenResponse is the successful response of token request.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// requestToken sends the token request with the form.
+func (options *OAuth2) requestToken(form *PostForm) (*Token, error) {
+	if len(options.Scopes) > 0 {
+		form.Set("scope", strings.Join(options.Scopes, " "))
+	}
+	if options.EndpointParams != nil {
+		for key, values := range options.EndpointParams.data {
+			for _, value := range values {
+				form.Add(key, value)
+			}
+		}
+	}
+	args := []RequestOption{form, NewHeaders("Accept", "application/json")}
+	if options.ClientAuthInBody {
+		form.Set("client_id", options.ClientID)
+		if options.ClientSecret != "" {
+			form.Set("client_secret", options.ClientSecret)
+		}
+	} else {
+		args = append(args, &BasicAuth{options.ClientID, options.ClientSecret})
+	}
+
+	session := options.Session
+	if session == nil {
+		session = DefaultSession()
+	}
+	resp, err := session.Post(options.TokenURL, args...)
+	if err != nil {
+		return nil, WrapErr(err, "request oauth2 token failed")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, WrapErr(newHTTPError(resp), "request oauth2 token failed")
+	}
+	return parseTokenResponse(resp)
+}
+
+// parseTokenResponse parse the token from the response of token request.
+func parseTokenResponse(resp *Response) (*Token, error) {
+	var tr tokenResponse
+	if err := resp.Json(&tr); err != nil {
+		return nil, WrapErr(err, "parse oauth2 token failed")
+	}
+	if tr.AccessToken == "" {
+		return nil, WrapErr(ErrAuthChallenge, "server response missing access_token")
+	}
+	token := &Token{
+		AccessToken:  tr.AccessToken,
+		TokenType:    tr.TokenType,
+		RefreshToken: tr.RefreshToken,
+	}
+	if tr.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// oauth2Authorizer authenticates a Request with OAuth2.
+type oauth2Authorizer struct {
+	auth    *OAuth2
+	token   *Token
+	retried bool
+}
+
+func (o *oauth2Authorizer) authorize(req *http.Request) error {
+	token, err := o.auth.Token()
+	if err != nil {
+		return err
+	}
+	o.token = token
+	req.Header.Set("Authorization", token.authorization())
+	return nil
+}
+
+func (o *oauth2Authorizer) challenge(resp *Response) (bool, error) {
+	if o.retried || o.token == nil {
+		return false, nil
+	}
+	o.retried = true
+	if err := o.auth.invalidate(o.token); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,249 @@
+package direwolf
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultLoginTimeout is the default time to wait for user login.
+const defaultLoginTimeout = 5 * time.Minute
+
+// AuthCodeFlow is the OAuth2 authorization code flow with PKCE, it is used
+// by CLI tools to let users log in interactively. Like this:
+//
+//	flow := &dw.AuthCodeFlow{
+//		AuthURL:  "https://example.com/oauth/authorize",
+//		TokenURL: "https://example.com/oauth/token",
+//		ClientID: "cli",
+//		Scopes:   []string{"read"},
+//		OpenURL:  dw.OpenBrowser,
+//	}
+//	session, err := flow.LoginSession(dw.NewSession())
+//
+// It starts a loopback server to receive the authorization code, shows the
+// authorize url to user, and exchanges the code for the token.
+type AuthCodeFlow struct {
+	AuthURL      string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string // optional, public clients do not have it
+	Scopes       []string
+
+	// AuthParams are the extra parameters of the authorize url.
+	AuthParams *Params
+
+	// RedirectPort is the port of the loopback server, default is a random port.
+	RedirectPort int
+
+	// RedirectPath is the path of the redirect uri, default is "/callback".
+	RedirectPath string
+
+	// OpenURL is called with the authorize url. Default prints it to Output,
+	// set it to OpenBrowser to open it in the browser.
+	OpenURL func(authURL string) error
+
+	// Output is where the authorize url is printed, default is os.Stderr.
+	Output io.Writer
+
+	// Timeout is the time to wait for user login, default is 5 minutes.
+	Timeout time.Duration
+
+	// Store is the token storage of the returned OAuth2.
+	Store TokenStore
+
+	// Session is used to exchange the token, default is DefaultSession().
+	Session *Session
+}
+
+// authCodeResult is the result of the loopback callback.
+type authCodeResult struct {
+	code string
+	err  error
+}
+
+// Login runs the flow, it returns an OAuth2 with the token, which refreshes the
+// token by refresh token automatically.
+func (flow *AuthCodeFlow) Login() (*OAuth2, error) {
+	verifier, err := randomBase64URL(32)
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomBase64URL(16)
+	if err != nil {
+		return nil, err
+	}
+
+	// start the loopback server to receive the code.
+	listener, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(flow.RedirectPort))
+	if err != nil {
+		return nil, WrapErr(err, "start loopback server failed")
+	}
+	redirectPath := flow.RedirectPath
+	if redirectPath == "" {
+		redirectPath = "/callback"
+	}
+	redirectURI := "http://" + listener.Addr().String() + redirectPath
+
+	results := make(chan authCodeResult, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(redirectPath, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var result authCodeResult
+		if query.Get("state") != state {
+			result.err = WrapErr(ErrAuthCode, "state mismatch")
+		} else if e := query.Get("error"); e != "" {
+			result.err = WrapErrf(ErrAuthCode, "%s: %s", e, query.Get("error_description"))
+		} else if result.code = query.Get("code"); result.code == "" {
+			result.err = WrapErr(ErrAuthCode, "missing code")
+		}
+		if result.err != nil {
+			http.Error(w, "Login failed, please try again.", http.StatusBadRequest)
+		} else {
+			io.WriteString(w, "Login succeeded, you can close this window now.")
+		}
+		select {
+		case results <- result:
+		default: // only the first callback is used.
+		}
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Shutdown(context.Background())
+
+	authURL, err := flow.authorizeURL(redirectURI, state, pkceChallenge(verifier))
+	if err != nil {
+		return nil, err
+	}
+	if err := flow.openURL(authURL); err != nil {
+		return nil, WrapErr(err, "open authorize url failed")
+	}
+
+	timeout := flow.Timeout
+	if timeout == 0 {
+		timeout = defaultLoginTimeout
+	}
+	var result authCodeResult
+	select {
+	case result = <-results:
+	case <-time.After(timeout):
+		return nil, WrapErr(ErrAuthCode, "wait for login timeout")
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	// exchange the code for the token.
+	auth := &OAuth2{
+		TokenURL:         flow.TokenURL,
+		ClientID:         flow.ClientID,
+		ClientSecret:     flow.ClientSecret,
+		Scopes:           flow.Scopes,
+		ClientAuthInBody: flow.ClientSecret == "",
+		Store:            flow.Store,
+		Session:          flow.Session,
+	}
+	form := NewPostForm(
+		"grant_type", "authorization_code",
+		"code", result.code,
+		"redirect_uri", redirectURI,
+		"code_verifier", verifier,
+	)
+	token, err := auth.requestToken(form)
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.SetToken(token); err != nil {
+		return nil, WrapErr(err, "save oauth2 token failed")
+	}
+	return auth, nil
+}
+
+// LoginSession runs the flow, and returns a new Session that authenticates
+// every request with the token.
+func (flow *AuthCodeFlow) LoginSession(session *Session) (*Session, error) {
+	auth, err := flow.Login()
+	if err != nil {
+		return nil, err
+	}
+	return session.With(auth), nil
+}
+
+// authorizeURL build the authorize url.
+func (flow *AuthCodeFlow) authorizeURL(redirectURI, state, challenge string) (string, error) {
+	u, err := url.Parse(flow.AuthURL)
+	if err != nil {
+		return "", WrapErr(err, "URL error")
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", flow.ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("state", state)
+	query.Set("code_challenge", challenge)
+	query.Set("code_challenge_method", "S256")
+	if len(flow.Scopes) > 0 {
+		query.Set("scope", strings.Join(flow.Scopes, " "))
+	}
+	if flow.AuthParams != nil {
+		for key, values := range flow.AuthParams.data {
+			query[key] = append(query[key], values...)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// openURL shows the authorize url to user.
+func (flow *AuthCodeFlow) openURL(authURL string) error {
+	if flow.OpenURL != nil {
+		return flow.OpenURL(authURL)
+	}
+	output := flow.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	_, err := fmt.Fprintf(output, "Please open the following url in your browser to log in:\n\n%s\n\n", authURL)
+	return err
+}
+
+// OpenBrowser opens the url in the default browser of the system.
+func OpenBrowser(URL string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", URL)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", URL)
+	default:
+		cmd = exec.Command("xdg-open", URL)
+	}
+	return cmd.Start()
+}
+
+// pkceChallenge returns the S256 code challenge of the verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomBase64URL returns a random base64 url encoded string of n bytes.
+func randomBase64URL(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", WrapErr(err, "generate random bytes failed")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,113 @@
+package direwolf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newTestAuthCodeServer is a stand-in authorization server which supports the
+// authorization code flow with PKCE.
+func newTestAuthCodeServer() *httptest.Server {
+	var challenge, redirectURI string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("client_id") != "cli" || query.Get("code_challenge_method") != "S256" ||
+			query.Get("response_type") != "code" || query.Get("scope") != "read write" {
+			w.WriteHeader(400)
+			return
+		}
+		challenge = query.Get("code_challenge")
+		redirectURI = query.Get("redirect_uri")
+		http.Redirect(w, r, redirectURI+"?code=authcode&state="+url.QueryEscape(query.Get("state")), 302)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("code") != "authcode" ||
+			r.PostFormValue("client_id") != "cli" || r.PostFormValue("redirect_uri") != redirectURI ||
+			pkceChallenge(r.PostFormValue("code_verifier")) != challenge {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"pkce","token_type":"bearer","expires_in":3600,"refresh_token":"refresh"}`))
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer pkce" {
+			w.WriteHeader(401)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestAuthCodeFlow(t *testing.T) {
+	ts := newTestAuthCodeServer()
+	defer ts.Close()
+
+	flow := &AuthCodeFlow{
+		AuthURL:  ts.URL + "/authorize",
+		TokenURL: ts.URL + "/token",
+		ClientID: "cli",
+		Scopes:   []string{"read", "write"},
+		OpenURL: func(authURL string) error {
+			// act as the browser of user.
+			go http.Get(authURL)
+			return nil
+		},
+	}
+	session, err := flow.LoginSession(NewSession())
+	if err != nil {
+		t.Fatal("AuthCodeFlow failed: ", err)
+	}
+	resp, err := session.Get(ts.URL + "/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "ok" {
+		t.Fatal("AuthCodeFlow failed.")
+	}
+}
+
+func TestAuthCodeFlowError(t *testing.T) {
+	flow := &AuthCodeFlow{
+		AuthURL: "http://127.0.0.1/authorize",
+		OpenURL: func(authURL string) error {
+			u, _ := url.Parse(authURL)
+			redirectURI := u.Query().Get("redirect_uri")
+			go http.Get(redirectURI + "?code=authcode&state=wrong")
+			return nil
+		},
+	}
+	if _, err := flow.Login(); !errors.Is(err, ErrAuthCode) {
+		t.Fatal("AuthCodeFlow state check failed: ", err)
+	}
+
+	flow.OpenURL = func(authURL string) error {
+		u, _ := url.Parse(authURL)
+		query := u.Query()
+		go http.Get(query.Get("redirect_uri") + "?error=access_denied&state=" + url.QueryEscape(query.Get("state")))
+		return nil
+	}
+	if _, err := flow.Login(); !errors.Is(err, ErrAuthCode) {
+		t.Fatal("AuthCodeFlow error check failed: ", err)
+	}
+
+	flow.OpenURL = func(authURL string) error { return nil }
+	flow.Timeout = 100 * time.Millisecond
+	if _, err := flow.Login(); !errors.Is(err, ErrAuthCode) {
+		t.Fatal("AuthCodeFlow timeout failed: ", err)
+	}
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	// RFC 7636 Appendix B.
+	if pkceChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk") != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Fatal("pkceChallenge failed.")
+	}
+}
@@ -0,0 +1,144 @@
+package direwolf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testOAuth2Server is a stand-in authorization and resource server.
+type testOAuth2Server struct {
+	mu          sync.Mutex
+	issued      int
+	validToken  string
+	expiresIn   int
+	grantTypes  []string
+	refreshUsed string
+}
+
+func (s *testOAuth2Server) handler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case "/token":
+		id, secret, ok := r.BasicAuth()
+		if !ok {
+			id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+		}
+		if id != "client" || secret != "secret" {
+			w.WriteHeader(401)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		grantType := r.PostFormValue("grant_type")
+		s.grantTypes = append(s.grantTypes, grantType)
+		if grantType == "refresh_token" {
+			s.refreshUsed = r.PostFormValue("refresh_token")
+		}
+		s.issued++
+		s.validToken = "token" + strconv.Itoa(s.issued)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"` + s.validToken + `","token_type":"bearer","expires_in":` +
+			strconv.Itoa(s.expiresIn) + `,"refresh_token":"refresh` + strconv.Itoa(s.issued) + `"}`))
+	case "/api":
+		if r.Header.Get("Authorization") != "Bearer "+s.validToken {
+			w.WriteHeader(401)
+			return
+		}
+		w.Write([]byte("ok " + s.validToken))
+	}
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	server := &testOAuth2Server{expiresIn: 3600}
+	ts := httptest.NewServer(http.HandlerFunc(server.handler))
+	defer ts.Close()
+
+	auth := NewOAuth2ClientCredentials(ts.URL+"/token", "client", "secret", "read")
+	session := NewSession().With(auth)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := session.Get(ts.URL + "/api")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if resp.Text() != "ok token1" {
+				t.Error("OAuth2 failed: ", resp.Text())
+			}
+		}()
+	}
+	wg.Wait()
+	if server.issued != 1 {
+		t.Fatal("OAuth2 token should be cached: ", server.issued)
+	}
+
+	// server revokes the token, it should be refreshed and retried once.
+	server.mu.Lock()
+	server.validToken = "revoked"
+	server.mu.Unlock()
+	resp, err := session.Get(ts.URL + "/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "ok token2" {
+		t.Fatal("OAuth2 refresh on 401 failed: ", resp.Text())
+	}
+	if server.grantTypes[1] != "refresh_token" || server.refreshUsed != "refresh1" {
+		t.Fatal("OAuth2 refresh token failed: ", server.grantTypes)
+	}
+}
+
+func TestOAuth2ExpiryAndStore(t *testing.T) {
+	server := &testOAuth2Server{expiresIn: 5}
+	ts := httptest.NewServer(http.HandlerFunc(server.handler))
+	defer ts.Close()
+
+	// token expires in 5 seconds, it is refreshed proactively.
+	store := &MemoryTokenStore{}
+	auth := &OAuth2{
+		TokenURL:         ts.URL + "/token",
+		ClientID:         "client",
+		ClientSecret:     "secret",
+		ClientAuthInBody: true,
+		Store:            store,
+	}
+	for i := 1; i <= 2; i++ {
+		resp, err := Get(ts.URL+"/api", auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Text() != "ok token"+strconv.Itoa(i) {
+			t.Fatal("OAuth2 expiry failed: ", resp.Text())
+		}
+	}
+	token, _ := store.Load()
+	if token.AccessToken != "token2" || token.RefreshToken != "refresh2" || time.Until(token.Expiry) > 5*time.Second {
+		t.Fatal("OAuth2 store failed: ", token)
+	}
+
+	// refresh token flow
+	auth = NewOAuth2RefreshToken(ts.URL+"/token", "client", "secret", "my-refresh")
+	resp, err := Get(ts.URL+"/api", auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "ok token3" || server.refreshUsed != "my-refresh" {
+		t.Fatal("OAuth2 refresh token flow failed: ", resp.Text())
+	}
+
+	// invalid client
+	_, err = Get(ts.URL+"/api", NewOAuth2ClientCredentials(ts.URL+"/token", "client", "wrong"))
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 401 {
+		t.Fatal("OAuth2 invalid client failed: ", err)
+	}
+}
@@ -0,0 +1,118 @@
+package direwolf
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RedirectPolicy controls how to follow redirects, one of the Request Options.
+// It can be set to Session as the default policy too. Like this:
+//
+//	policy := &dw.RedirectPolicy{
+//		SameHost:  true,
+//		StripAuth: true,
+//	}
+type RedirectPolicy struct {
+	// SameHost, if true, only allows redirecting to the host of the
+	// original request.
+	SameHost bool
+
+	// AllowedHosts, if not empty, only allows redirecting to the given
+	// hosts. The host of the original request is always allowed.
+	AllowedHosts []string
+
+	// KeepMethod, if true, 301 and 302 redirects will not rewrite
+	// the method to GET, the method and body of the previous request will
+	// be kept, so a 303 still changes it to GET. The redirect fails with
+	// ErrRedirectNotAllowed if the body can not be resent.
+	KeepMethod bool
+
+	// StripAuth, if true, Authorization and Cookie headers will be removed
+	// when redirect to a different origin.
+	StripAuth bool
+
+	// ReturnLast, if true, returns the last 3xx response instead of a
+	// RedirectError when the redirect number is exceeded.
+	ReturnLast bool
+
+	// CheckRedirect is called before each redirect, it can modify the
+	// next request. Return an error to stop redirecting, or return
+	// http.ErrUseLastResponse to use the last response.
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+}
+
+// RequestOption interface method, bind request option to request.
+func (options *RedirectPolicy) bindRequest(request *Request) error {
+	request.RedirectPolicy = options
+	return nil
+}
+
+// check apply the policy to the next request before redirecting.
+func (policy *RedirectPolicy) check(req *http.Request, via []*http.Request) error {
+	original := via[0]
+	if policy.SameHost && !strings.EqualFold(req.URL.Host, original.URL.Host) {
+		return WrapErrf(ErrRedirectNotAllowed, "redirect to %s", req.URL.Host)
+	}
+	if len(policy.AllowedHosts) > 0 && !policy.isAllowedHost(req, original) {
+		return WrapErrf(ErrRedirectNotAllowed, "redirect to %s", req.URL.Host)
+	}
+
+	if policy.StripAuth && !sameOrigin(req, via[len(via)-1]) {
+		req.Header.Del("Authorization")
+		req.Header.Del("Cookie")
+	}
+
+	if policy.KeepMethod {
+		// keep the method of the previous request, so that the method
+		// changed by 303 is not restored.
+		previous := via[len(via)-1]
+		if req.Response != nil && (req.Response.StatusCode == 301 || req.Response.StatusCode == 302) {
+			req.Method = previous.Method
+		}
+		// http.Client drops the body once the method is rewritten, restore it.
+		// The body can not be restored without GetBody, fail instead of
+		// sending the request without body.
+		hasBody := previous.Body != nil && previous.Body != http.NoBody
+		if req.Method == previous.Method && req.Body == nil && hasBody && previous.GetBody == nil {
+			return WrapErrf(ErrRedirectNotAllowed, "can not resend the body of %s request", previous.Method)
+		}
+		if req.Method == previous.Method && req.Body == nil && previous.GetBody != nil {
+			body, err := previous.GetBody()
+			if err != nil {
+				return WrapErr(err, "get request body failed")
+			}
+			req.Body = body
+			req.GetBody = previous.GetBody
+			req.ContentLength = previous.ContentLength
+			for _, key := range []string{"Content-Type", "Content-Encoding"} {
+				if value := previous.Header.Get(key); value != "" {
+					req.Header.Set(key, value)
+				}
+			}
+		}
+	}
+
+	if policy.CheckRedirect != nil {
+		return policy.CheckRedirect(req, via)
+	}
+	return nil
+}
+
+// isAllowedHost check whether the host of req is the original host or
+// in the AllowedHosts.
+func (policy *RedirectPolicy) isAllowedHost(req, original *http.Request) bool {
+	if strings.EqualFold(req.URL.Host, original.URL.Host) {
+		return true
+	}
+	for _, host := range policy.AllowedHosts {
+		if strings.EqualFold(req.URL.Host, host) || strings.EqualFold(req.URL.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin check whether the two requests have the same scheme, host and port.
+func sameOrigin(req1, req2 *http.Request) bool {
+	return strings.EqualFold(req1.URL.Scheme, req2.URL.Scheme) && strings.EqualFold(req1.URL.Host, req2.URL.Host)
+}
@@ -0,0 +1,230 @@
+package direwolf
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newTestRedirectPolicyServers returns two servers, origin redirects to target.
+func newTestRedirectPolicyServers() (origin, target *httptest.Server) {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(body) > 0 {
+			w.Write([]byte(string(body) + "|"))
+		}
+		w.Write([]byte(r.Method + "|" + r.Header.Get("Authorization") + "|" + r.Header.Get("Cookie") + "|" + r.Header.Get("X-Hop")))
+	}
+	target = httptest.NewServer(http.HandlerFunc(echo))
+	origin = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cross":
+			http.Redirect(w, r, target.URL+"/echo", 302)
+		case "/302":
+			http.Redirect(w, r, "/echo", 302)
+		case "/303":
+			http.Redirect(w, r, "/302", 303)
+		case "/307":
+			http.Redirect(w, r, "/echo", 307)
+		case "/308":
+			http.Redirect(w, r, target.URL+"/echo", 308)
+		case "/loop":
+			http.Redirect(w, r, "/loop", 302)
+		default:
+			echo(w, r)
+		}
+	}))
+	return origin, target
+}
+
+func TestRedirectPolicyHost(t *testing.T) {
+	origin, target := newTestRedirectPolicyServers()
+	defer origin.Close()
+	defer target.Close()
+
+	_, err := Get(origin.URL+"/cross", &RedirectPolicy{SameHost: true})
+	if !errors.Is(err, ErrRedirectNotAllowed) {
+		t.Fatal("RedirectPolicy.SameHost failed: ", err)
+	}
+	resp, err := Get(origin.URL+"/302", &RedirectPolicy{SameHost: true})
+	if err != nil || resp.Text() != "GET|||" {
+		t.Fatal("RedirectPolicy.SameHost failed: ", err)
+	}
+
+	targetURL, _ := url.Parse(target.URL)
+	_, err = Get(origin.URL+"/cross", &RedirectPolicy{AllowedHosts: []string{"example.com"}})
+	if !errors.Is(err, ErrRedirectNotAllowed) {
+		t.Fatal("RedirectPolicy.AllowedHosts failed: ", err)
+	}
+	resp, err = Get(origin.URL+"/cross", &RedirectPolicy{AllowedHosts: []string{targetURL.Host}})
+	if err != nil || resp.URL != target.URL+"/echo" {
+		t.Fatal("RedirectPolicy.AllowedHosts failed: ", err)
+	}
+}
+
+func TestRedirectPolicyStripAuth(t *testing.T) {
+	origin, target := newTestRedirectPolicyServers()
+	defer origin.Close()
+	defer target.Close()
+
+	headers := NewHeaders("Authorization", "Bearer direwolf")
+	cookies := NewCookies("key", "value")
+	resp, err := Get(origin.URL+"/cross", headers, cookies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "GET|Bearer direwolf|key=value|" {
+		t.Fatal("RedirectPolicy.StripAuth failed: ", resp.Text())
+	}
+
+	resp, err = Get(origin.URL+"/cross", headers, cookies, &RedirectPolicy{StripAuth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "GET|||" {
+		t.Fatal("RedirectPolicy.StripAuth failed: ", resp.Text())
+	}
+
+	// same origin redirect keeps the headers.
+	resp, err = Get(origin.URL+"/302", headers, &RedirectPolicy{StripAuth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "GET|Bearer direwolf||" {
+		t.Fatal("RedirectPolicy.StripAuth failed: ", resp.Text())
+	}
+}
+
+func TestRedirectPolicyKeepMethod(t *testing.T) {
+	origin, target := newTestRedirectPolicyServers()
+	defer origin.Close()
+	defer target.Close()
+
+	resp, err := Post(origin.URL + "/302")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "GET|||" {
+		t.Fatal("RedirectPolicy.KeepMethod failed: ", resp.Text())
+	}
+
+	resp, err = Post(origin.URL+"/302", &RedirectPolicy{KeepMethod: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "POST|||" {
+		t.Fatal("RedirectPolicy.KeepMethod failed: ", resp.Text())
+	}
+
+	resp, err = Post(origin.URL+"/302", Body("key=value"), &RedirectPolicy{KeepMethod: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "key=value|POST|||" {
+		t.Fatal("RedirectPolicy.KeepMethod failed: ", resp.Text())
+	}
+
+	// the method changed by 303 is kept on the following redirects.
+	resp, err = Post(origin.URL+"/303", Body("key=value"), &RedirectPolicy{KeepMethod: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "GET|||" {
+		t.Fatal("RedirectPolicy.KeepMethod after 303 failed: ", resp.Text())
+	}
+
+	// the body which can not be replayed is not dropped silently.
+	original, _ := http.NewRequest("POST", origin.URL+"/302", ioutil.NopCloser(strings.NewReader("key=value")))
+	original.GetBody = nil
+	next, _ := http.NewRequest("GET", origin.URL+"/", nil)
+	next.Response = &http.Response{StatusCode: 302}
+	err = (&RedirectPolicy{KeepMethod: true}).check(next, []*http.Request{original})
+	if !errors.Is(err, ErrRedirectNotAllowed) {
+		t.Fatal("RedirectPolicy.KeepMethod without GetBody failed: ", err)
+	}
+}
+
+func TestRedirectReplayBody(t *testing.T) {
+	origin, target := newTestRedirectPolicyServers()
+	defer origin.Close()
+	defer target.Close()
+
+	tests := []struct {
+		path   string
+		option RequestOption
+		body   string
+	}{
+		{"/307", Body("key=value"), "key=value"},
+		{"/308", Body("key=value"), "key=value"},
+		{"/307", NewPostForm("key", "value"), "key=value"},
+		{"/308", JsonBody(`{"key":"value"}`), `{"key":"value"}`},
+	}
+	for _, test := range tests {
+		resp, err := Post(origin.URL+test.path, test.option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "POST|||"
+		if test.body != "" {
+			expected = test.body + "|" + expected
+		}
+		if resp.Text() != expected {
+			t.Fatal("Replay request body failed: ", test.path, resp.Text())
+		}
+	}
+}
+
+func TestRedirectPolicyReturnLast(t *testing.T) {
+	origin, target := newTestRedirectPolicyServers()
+	defer origin.Close()
+	defer target.Close()
+
+	resp, err := Get(origin.URL+"/loop", RedirectNum(2), &RedirectPolicy{ReturnLast: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 302 || len(resp.History) != 2 {
+		t.Fatal("RedirectPolicy.ReturnLast failed.")
+	}
+}
+
+func TestRedirectPolicyCheckRedirect(t *testing.T) {
+	origin, target := newTestRedirectPolicyServers()
+	defer origin.Close()
+	defer target.Close()
+
+	policy := &RedirectPolicy{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Path == "/loop" {
+				return errors.New("veto")
+			}
+			req.Header.Set("X-Hop", "1")
+			return nil
+		},
+	}
+	session := NewSession()
+	session.RedirectPolicy = policy
+	resp, err := session.Get(origin.URL + "/302")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "GET|||1" {
+		t.Fatal("RedirectPolicy.CheckRedirect failed: ", resp.Text())
+	}
+	if _, err := session.Get(origin.URL + "/loop"); err == nil {
+		t.Fatal("RedirectPolicy.CheckRedirect failed.")
+	}
+
+	// Request has higher priority.
+	resp, err = session.Get(origin.URL+"/302", &RedirectPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "GET|||" {
+		t.Fatal("RedirectPolicy.CheckRedirect failed: ", resp.Text())
+	}
+}
@@ -8,17 +8,22 @@ import (
 // Request is a prepared request setting, you should construct it by using
 // NewRequest().
 type Request struct {
-	Method      string
-	URL         string
-	Headers     http.Header
-	Body        []byte
-	JsonBody    []byte
-	Params      *Params
-	PostForm    *PostForm
-	Cookies     Cookies
-	Proxy       *Proxy
-	RedirectNum int
-	Timeout     int
+	Method         string
+	URL            string
+	Headers        http.Header
+	Body           []byte
+	JsonBody       []byte
+	Params         *Params
+	PostForm       *PostForm
+	Cookies        Cookies
+	Proxy          *Proxy
+	Compress       *Compress
+	RedirectNum    int
+	RedirectPolicy *RedirectPolicy
+	Merge          *Merge
+	Auth           Auth
+	Timeout        int
+	Timeouts       *Timeouts
 }
 
 // NewRequest construct a Request by passing the parameters.
@@ -32,8 +37,13 @@ type Request struct {
 // 	direwolf.PostForm: Post data form to send.
 // 	direwolf.Body: Post body to send.
 // 	direwolf.Proxy: Proxy url to use.
+// 	direwolf.Compress: Compress the request body.
 // 	direwolf.Timeout: Request Timeout.
+// 	direwolf.Timeouts: Timeouts of each phase of the request.
 // 	direwolf.RedirectNum: Number of Request allowed to redirect.
+// 	direwolf.RedirectPolicy: How to follow redirects.
+// 	direwolf.Merge: How to merge with Session Headers, Cookies and Params.
+// 	direwolf.BasicAuth, DigestAuth, NTLMAuth: Authentication of the request.
 func NewRequest(method string, URL string, args ...RequestOption) (req *Request, err error) {
 	req = &Request{}                     // new a Request and set default field
 	req.Method = strings.ToUpper(method) // Upper the method string
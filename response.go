@@ -13,6 +13,8 @@ import (
 )
 
 // Response is the response from request.
+// URL is the effective final url after redirects, History records the
+// redirect responses in order.
 type Response struct {
 	URL           string
 	StatusCode    int
@@ -22,11 +24,30 @@ type Response struct {
 	Request       *Request
 	Content       []byte
 	ContentLength int64
+	Timings       Timings
+	History       []*RedirectHop
 	encoding      string
 	text          string
 	dom           *goquery.Document
 }
 
+// RedirectHop is one redirect response in the redirect chain.
+type RedirectHop struct {
+	StatusCode int
+	URL        string
+	Headers    http.Header
+	Cookies    Cookies // cookies from the Set-Cookie headers
+}
+
+// RaiseForStatus return a HTTPError if the status code of response is 4xx or 5xx.
+// Otherwise it returns nil.
+func (resp *Response) RaiseForStatus() error {
+	if resp.StatusCode >= 400 && resp.StatusCode < 600 {
+		return WrapErr(newHTTPError(resp), "RaiseForStatus")
+	}
+	return nil
+}
+
 // Encoding can change and return the encoding type of response. Like this:
 //   encoding := resp.Encoding("GBK")
 // You can specified encoding type. Such as GBK, GB18030, latin1. Default is UTF-8.
@@ -1,7 +1,9 @@
 package direwolf
 
 import (
+	"errors"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -40,10 +42,44 @@ func newTestResponseServer() *httptest.Server {
 		content, _ := charmap.ISO8859_1.NewEncoder().Bytes([]byte(`<li><a href="/author/">...</a></li>`))
 		c.Data(200, "text/html", content)
 	})
+	router.GET("/status/:code", func(c *gin.Context) {
+		code, _ := strconv.Atoi(c.Param("code"))
+		c.Header("X-Status", c.Param("code"))
+		c.String(code, "status "+c.Param("code"))
+	})
 	ts := httptest.NewServer(router)
 	return ts
 }
 
+func TestRaiseForStatus(t *testing.T) {
+	ts := newTestResponseServer()
+	defer ts.Close()
+
+	resp, err := Get(ts.URL + "/status/200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.RaiseForStatus(); err != nil {
+		t.Fatal("Response.RaiseForStatus() failed.")
+	}
+
+	resp, err = Get(ts.URL + "/status/503")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = resp.RaiseForStatus()
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatal("Response.RaiseForStatus() failed.")
+	}
+	if httpErr.StatusCode != 503 || httpErr.Headers.Get("X-Status") != "503" {
+		t.Fatal("Response.RaiseForStatus() failed.")
+	}
+	if string(httpErr.Body) != "status 503" || httpErr.Request.URL != ts.URL+"/status/503" {
+		t.Fatal("Response.RaiseForStatus() failed.")
+	}
+}
+
 func TestReExtract(t *testing.T) {
 	ts := newTestResponseServer()
 	defer ts.Close()
@@ -0,0 +1,60 @@
+package direwolf
+
+import (
+	"errors"
+	"time"
+)
+
+// Retry is the policy to retry the failed requests of Session, one of the
+// SessionOptions. A request is retried if it fails in network level, such as
+// connection refused and timeout, or the status code of response is in
+// StatusCodes. Only the idempotent requests are retried, they are GET, HEAD,
+// OPTIONS, PUT, DELETE and TRACE.
+type Retry struct {
+	// Max is the max number of retries, zero means no retry.
+	Max int
+
+	// Backoff is the time to wait before the first retry, it doubles
+	// before every next retry. Zero means retry immediately.
+	Backoff time.Duration
+
+	// StatusCodes, if not empty, the responses of these status codes are
+	// retried, such as 502 and 503.
+	StatusCodes []int
+}
+
+// shouldRetry check whether the request should be retried with the result
+// of last attempt.
+func (retry *Retry) shouldRetry(req *Request, resp *Response, err error) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE", "TRACE":
+	default:
+		return false
+	}
+	if err != nil {
+		var reqErr *RequestError
+		return errors.As(err, &reqErr) && reqErr.Kind != ErrRedirect
+	}
+	for _, code := range retry.StatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the time to wait before the nth retry, n starts from 0.
+func (retry *Retry) backoff(n int) time.Duration {
+	return retry.Backoff << uint(n)
+}
+
+// sendWithRetry send the request, and retry it by the Retry of session.
+func sendWithRetry(session *Session, req *Request) (*Response, error) {
+	for n := 0; ; n++ {
+		resp, err := sendWithAuth(session, req)
+		if session.retry == nil || n >= session.retry.Max || !session.retry.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+		time.Sleep(session.retry.backoff(n))
+	}
+}
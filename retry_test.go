@@ -0,0 +1,102 @@
+package direwolf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestRetryServer returns a server responds 503 for the first failures
+// requests, and counts the requests.
+func newTestRetryServer(failures int32, count *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(count, 1) <= failures {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte("successed"))
+	}))
+}
+
+func TestRetry(t *testing.T) {
+	var count int32
+	ts := newTestRetryServer(2, &count)
+	defer ts.Close()
+
+	options := DefaultSessionOptions()
+	options.Retry = &Retry{Max: 2, Backoff: 10 * time.Millisecond, StatusCodes: []int{503}}
+	session := NewSession(options)
+	start := time.Now()
+	resp, err := session.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "successed" || count != 3 || time.Since(start) < 30*time.Millisecond {
+		t.Fatal("Retry failed: ", resp.StatusCode, count)
+	}
+
+	// the retries are limited by Max.
+	atomic.StoreInt32(&count, -10)
+	resp, err = session.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 503 || count != -7 {
+		t.Fatal("Retry.Max failed: ", resp.StatusCode, count)
+	}
+
+	// POST is not idempotent.
+	atomic.StoreInt32(&count, 0)
+	resp, err = session.Post(ts.URL, Body("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 503 || count != 1 {
+		t.Fatal("Retry POST failed: ", resp.StatusCode, count)
+	}
+}
+
+type countTransport struct {
+	count int32
+}
+
+func (t *countTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.count, 1)
+	return nil, syscall.ECONNREFUSED
+}
+
+func TestRetryRequestError(t *testing.T) {
+	transport := &countTransport{}
+	options := DefaultSessionOptions()
+	options.Transport = transport
+	options.Retry = &Retry{Max: 3}
+	_, err := NewSession(options).Get("http://127.0.0.1/")
+	if !errors.Is(err, ErrConnRefused) || transport.count != 4 {
+		t.Fatal("Retry request error failed: ", err, transport.count)
+	}
+}
+
+func TestConfigureDefaultRetry(t *testing.T) {
+	var count int32
+	ts := newTestRetryServer(1, &count)
+	defer ts.Close()
+	previous := DefaultSession()
+	defer SetDefaultSession(previous)
+
+	options := DefaultSessionOptions()
+	options.Retry = &Retry{Max: 1, StatusCodes: []int{503}}
+	if err := ConfigureDefault(options); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "successed" || count != 2 {
+		t.Fatal("ConfigureDefault Retry failed: ", resp.StatusCode, count)
+	}
+}
@@ -1,10 +1,14 @@
 package direwolf
 
 import (
+	"crypto/tls"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,12 +20,27 @@ import (
 // Session is the main object in direwolf. This is its main features:
 // 1. handling redirects
 // 2. automatically managing cookies
+//
+// Headers, Proxy, Compress, RedirectPolicy, Timeout and Timeouts can be set directly
+// before the Session is used. If the Session is shared between goroutines,
+// please use the setters such as SetHeader and SetProxy to change them at
+// runtime, every request uses a snapshot of them.
 type Session struct {
-	client    *http.Client
-	transport *http.Transport
-	Headers   http.Header
-	Proxy     *Proxy
-	Timeout   int
+	mu             sync.RWMutex
+	client         *http.Client
+	transport      *http.Transport
+	raiseForStatus bool
+	raiseCodes     map[int]bool
+	baseURL        *url.URL
+	netrc          *Netrc
+	retry          *Retry
+	defaults       []RequestOption
+	Headers        http.Header
+	Proxy          *Proxy
+	Compress       *Compress
+	RedirectPolicy *RedirectPolicy
+	Timeout        int
+	Timeouts       *Timeouts
 }
 
 // NewSession new a Session object, and set a default Client and Transport.
@@ -46,6 +65,7 @@ func NewSession(options ...*SessionOptions) *Session {
 		TLSHandshakeTimeout:   sessionOptions.TLSHandshakeTimeout,
 		ExpectContinueTimeout: sessionOptions.ExpectContinueTimeout,
 		Proxy:                 proxyFunc,
+		TLSClientConfig:       sessionOptions.TLSClientConfig,
 	}
 	if sessionOptions.DisableDialKeepAlives {
 		trans.DisableKeepAlives = true
@@ -55,6 +75,15 @@ func NewSession(options ...*SessionOptions) *Session {
 		Transport:     trans,
 		CheckRedirect: redirectFunc,
 	}
+	if sessionOptions.Transport != nil {
+		client.Transport = sessionOptions.Transport
+	}
+	if len(sessionOptions.Faults) > 0 {
+		client.Transport = sessionOptions.Faults.Wrap(client.Transport)
+	}
+	if sessionOptions.Debug != nil {
+		client.Transport = sessionOptions.Debug.Wrap(client.Transport)
+	}
 
 	// set CookieJar
 	if sessionOptions.DisableCookieJar == false {
@@ -71,23 +100,243 @@ func NewSession(options ...*SessionOptions) *Session {
 	// Set default user agent
 	headers := http.Header{}
 	headers.Add("User-Agent", "direwolf - winter is coming")
+	for key, values := range sessionOptions.Headers {
+		headers[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+
+	// set the base url of relative request url
+	var baseURL *url.URL
+	if sessionOptions.BaseURL != "" {
+		u, err := url.Parse(sessionOptions.BaseURL)
+		if err != nil {
+			return nil
+		}
+		if !strings.HasSuffix(u.Path, "/") { // so that the last path segment will be kept
+			u.Path += "/"
+		}
+		baseURL = u
+	}
+
+	// read the credentials of .netrc, it is ignored if the file does not exist.
+	var netrc *Netrc
+	if sessionOptions.Netrc {
+		path := sessionOptions.NetrcPath
+		if path == "" {
+			path = DefaultNetrcPath()
+		}
+		n, err := ReadNetrc(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		netrc = n
+	}
+
+	// set the status codes need to raise HTTPError
+	var raiseCodes map[int]bool
+	if len(sessionOptions.RaiseStatusCodes) > 0 {
+		raiseCodes = make(map[int]bool)
+		for _, code := range sessionOptions.RaiseStatusCodes {
+			raiseCodes[code] = true
+		}
+	}
 
 	return &Session{
-		client:    client,
-		transport: trans,
-		Headers:   headers,
+		client:         client,
+		transport:      trans,
+		raiseForStatus: sessionOptions.RaiseForStatus,
+		raiseCodes:     raiseCodes,
+		baseURL:        baseURL,
+		netrc:          netrc,
+		retry:          sessionOptions.Retry,
+		Headers:        headers,
+		Proxy:          sessionOptions.Proxy,
 	}
 }
 
 // Send is a generic request method.
 func (session *Session) Send(req *Request) (*Response, error) {
-	resp, err := send(session, req)
+	req, err := session.prepareRequest(req)
+	if err != nil {
+		return nil, WrapErr(err, "session send failed")
+	}
+	if req.Auth == nil && session.netrc != nil {
+		req.Auth = session.netrcAuth(req)
+	}
+	resp, err := sendWithRetry(session, req)
 	if err != nil {
 		return nil, WrapErr(err, "session send failed")
 	}
+	if err := session.checkStatus(resp); err != nil {
+		return nil, err
+	}
 	return resp, nil
 }
 
+// With returns a new Session with default request options, they will be merged
+// into every request sent by the new Session. Request has higher priority.
+// Like this:
+//
+//	api := session.With(
+//		dw.NewParams("token", "xxx"),
+//		dw.NewCookies("uid", "1"),
+//		dw.Timeout(10),
+//	)
+//
+// Default Params, Cookies and Headers are merged by key according to Merge,
+// Timeout, Timeouts, RedirectNum, Proxy, Compress, RedirectPolicy, Merge and
+// Auth are used if the request does not set them.
+// Body, JsonBody and PostForm are ignored.
+//
+// The new Session shares the connections and cookies with the original Session.
+func (session *Session) With(args ...RequestOption) *Session {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	return &Session{
+		client:         session.client,
+		transport:      session.transport,
+		raiseForStatus: session.raiseForStatus,
+		raiseCodes:     session.raiseCodes,
+		baseURL:        session.baseURL,
+		netrc:          session.netrc,
+		retry:          session.retry,
+		defaults:       append(append([]RequestOption{}, session.defaults...), args...),
+		Headers:        session.Headers.Clone(),
+		Proxy:          session.Proxy,
+		Compress:       session.Compress,
+		RedirectPolicy: session.RedirectPolicy,
+		Timeout:        session.Timeout,
+		Timeouts:       session.Timeouts,
+	}
+}
+
+// netrcAuth returns the BasicAuth of the request host from .netrc, it returns
+// nil if there is no credentials or the request or Session has Authorization
+// header.
+func (session *Session) netrcAuth(req *Request) Auth {
+	if req.Headers.Get("Authorization") != "" {
+		return nil
+	}
+	session.mu.RLock()
+	authorization := session.Headers.Get("Authorization")
+	session.mu.RUnlock()
+	if authorization != "" {
+		return nil
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil
+	}
+	machine := session.netrc.Machine(u.Hostname())
+	if machine == nil || (machine.Login == "" && machine.Password == "") {
+		return nil
+	}
+	return &BasicAuth{Username: machine.Login, Password: machine.Password}
+}
+
+// prepareRequest resolve the request url with the base url and merge the
+// default request options. It returns a copy of the request.
+func (session *Session) prepareRequest(req *Request) (*Request, error) {
+	prepared := *req
+	if session.baseURL != nil {
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			return nil, WrapErr(err, "URL error")
+		}
+		if !u.IsAbs() {
+			u.Path = strings.TrimPrefix(u.Path, "/")
+			prepared.URL = session.baseURL.ResolveReference(u).String()
+		}
+	}
+	if len(session.defaults) == 0 {
+		return &prepared, nil
+	}
+
+	defaults := &Request{}
+	for _, arg := range session.defaults {
+		if err := arg.bindRequest(defaults); err != nil {
+			return nil, WrapErr(err, "set default request parameters failed.")
+		}
+	}
+	if prepared.Merge == nil {
+		prepared.Merge = defaults.Merge
+	}
+
+	// Params have been joined to the url, so merge them with url query.
+	if defaults.URL != "" {
+		u, err := url.Parse(prepared.URL)
+		if err != nil {
+			return nil, WrapErr(err, "URL error")
+		}
+		query := u.Query()
+		defaultQuery, _ := url.ParseQuery(strings.TrimPrefix(defaults.URL, "?"))
+		extra := url.Values{}
+		for key, values := range defaultQuery {
+			switch prepared.Merge.paramMode(key) {
+			case MergeDelete:
+				continue
+			case MergeReplace:
+				if _, ok := query[key]; ok {
+					continue
+				}
+			}
+			extra[key] = values
+		}
+		// the default params are put before the request params, like the
+		// Headers and Cookies, so MergeAppend appends the request value.
+		if len(extra) > 0 {
+			rawQuery := extra.Encode()
+			if u.RawQuery != "" {
+				rawQuery += "&" + u.RawQuery
+			}
+			u.RawQuery = rawQuery
+			prepared.URL = u.String()
+		}
+	}
+	if defaults.Headers != nil {
+		prepared.Headers = mergeHeaders(req.Headers, defaults.Headers, prepared.Merge)
+	}
+	if defaults.Cookies != nil {
+		prepared.Cookies = mergeCookies(req.Cookies, defaults.Cookies, prepared.Merge)
+	}
+	if prepared.Timeout == 0 {
+		prepared.Timeout = defaults.Timeout
+	}
+	if prepared.Timeouts == nil {
+		prepared.Timeouts = defaults.Timeouts
+	}
+	if prepared.Auth == nil {
+		prepared.Auth = defaults.Auth
+	}
+	if prepared.RedirectNum == 0 {
+		prepared.RedirectNum = defaults.RedirectNum
+	}
+	if prepared.Proxy == nil {
+		prepared.Proxy = defaults.Proxy
+	}
+	if prepared.Compress == nil {
+		prepared.Compress = defaults.Compress
+	}
+	if prepared.RedirectPolicy == nil {
+		prepared.RedirectPolicy = defaults.RedirectPolicy
+	}
+	return &prepared, nil
+}
+
+// checkStatus return a HTTPError if RaiseForStatus is enabled and the status
+// code of response should be raised.
+func (session *Session) checkStatus(resp *Response) error {
+	if !session.raiseForStatus {
+		return nil
+	}
+	if session.raiseCodes != nil {
+		if session.raiseCodes[resp.StatusCode] {
+			return WrapErr(newHTTPError(resp), "session send failed")
+		}
+		return nil
+	}
+	return resp.RaiseForStatus()
+}
+
 // Get is a get method.
 func (session *Session) Get(URL string, args ...RequestOption) (*Response, error) {
 	req, err := NewRequest("GET", URL, args...)
@@ -166,31 +415,199 @@ func (session *Session) Delete(URL string, args ...RequestOption) (*Response, er
 	return resp, nil
 }
 
+// sessionSnapshot is a copy of the runtime settings of Session, it is taken
+// at the beginning of every request.
+type sessionSnapshot struct {
+	client         *http.Client
+	headers        http.Header
+	proxy          *Proxy
+	compress       *Compress
+	redirectPolicy *RedirectPolicy
+	timeout        int
+	timeouts       *Timeouts
+}
+
+// snapshot returns a copy of the runtime settings of Session.
+func (session *Session) snapshot() *sessionSnapshot {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	return &sessionSnapshot{
+		client:         session.client,
+		headers:        session.Headers.Clone(),
+		proxy:          session.Proxy,
+		compress:       session.Compress,
+		redirectPolicy: session.RedirectPolicy,
+		timeout:        session.Timeout,
+		timeouts:       session.Timeouts,
+	}
+}
+
+// SetHeader sets the Session header entries associated with key to the
+// single element value. It is safe for concurrent use.
+func (session *Session) SetHeader(key, value string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.Headers == nil {
+		session.Headers = http.Header{}
+	}
+	session.Headers.Set(key, value)
+}
+
+// AddHeader adds the key, value pair to the Session headers.
+// It is safe for concurrent use.
+func (session *Session) AddHeader(key, value string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.Headers == nil {
+		session.Headers = http.Header{}
+	}
+	session.Headers.Add(key, value)
+}
+
+// DelHeader deletes the values associated with key from the Session headers.
+// It is safe for concurrent use.
+func (session *Session) DelHeader(key string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.Headers.Del(key)
+}
+
+// SetHeaders replaces all the Session headers with a copy of headers.
+// It is safe for concurrent use.
+func (session *Session) SetHeaders(headers http.Header) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.Headers = headers.Clone()
+}
+
+// GetHeaders returns a copy of the Session headers.
+// It is safe for concurrent use.
+func (session *Session) GetHeaders() http.Header {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	return session.Headers.Clone()
+}
+
+// SetProxy sets the Session proxy, nil means no proxy.
+// It is safe for concurrent use.
+func (session *Session) SetProxy(proxy *Proxy) {
+	if proxy != nil {
+		p := *proxy // copy it, so that later changes of proxy will not race.
+		proxy = &p
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.Proxy = proxy
+}
+
+// GetProxy returns the Session proxy.
+// It is safe for concurrent use.
+func (session *Session) GetProxy() *Proxy {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	return session.Proxy
+}
+
+// SetTimeout sets the Session timeout.
+// It is safe for concurrent use.
+func (session *Session) SetTimeout(timeout int) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.Timeout = timeout
+}
+
+// GetTimeout returns the Session timeout.
+// It is safe for concurrent use.
+func (session *Session) GetTimeout() int {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	return session.Timeout
+}
+
+// SetTimeouts sets the Session timeouts of each phase.
+// It is safe for concurrent use.
+func (session *Session) SetTimeouts(timeouts *Timeouts) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.Timeouts = timeouts
+}
+
+// SetCompress sets the Session compress option.
+// It is safe for concurrent use.
+func (session *Session) SetCompress(compress *Compress) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.Compress = compress
+}
+
+// SetRedirectPolicy sets the Session redirect policy.
+// It is safe for concurrent use.
+func (session *Session) SetRedirectPolicy(policy *RedirectPolicy) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.RedirectPolicy = policy
+}
+
+// WrapTransport wraps the transport of the Session, such as recording or
+// stubbing the requests, so that the call sites need not be changed. Like this:
+//
+//	recorder := dw.NewHARRecorder()
+//	session.WrapTransport(recorder.Wrap)
+//
+// It can be called several times, the last wrapper is the outermost one.
+// It is safe for concurrent use. The Sessions returned by With before it is
+// called are not affected.
+func (session *Session) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	client := *session.client
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = wrap(transport)
+	session.client = &client
+}
+
+// cookieJar returns the cookie jar of the Session client.
+func (session *Session) cookieJar() http.CookieJar {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	return session.client.Jar
+}
+
 // Cookies returns the cookies of the given url in Session.
 func (session *Session) Cookies(URL string) Cookies {
-	if session.client.Jar == nil {
+	jar := session.cookieJar()
+	if jar == nil {
 		return nil
 	}
 	parsedURL, err := url.Parse(URL)
 	if err != nil {
 		return nil
 	}
-	return session.client.Jar.Cookies(parsedURL)
+	return jar.Cookies(parsedURL)
 }
 
 // SetCookies set cookies of the url in Session.
 func (session *Session) SetCookies(URL string, cookies Cookies) {
-	if session.client.Jar == nil {
+	jar := session.cookieJar()
+	if jar == nil {
 		return
 	}
 	parsedURL, err := url.Parse(URL)
 	if err != nil {
 		return
 	}
-	session.client.Jar.SetCookies(parsedURL, cookies)
+	jar.SetCookies(parsedURL, cookies)
 }
 
 type SessionOptions struct {
+	// BaseURL, if not empty, relative request url will be resolved against
+	// it. For example, BaseURL "http://example.com/api" and request url
+	// "/users" will be resolved to "http://example.com/api/users".
+	BaseURL string
+
 	// DialTimeout is the maximum amount of time a dial will wait for
 	// a connect to complete.
 	//
@@ -245,6 +662,15 @@ type SessionOptions struct {
 	// This time does not include the time to send the request header.
 	ExpectContinueTimeout time.Duration
 
+	// TLSClientConfig specifies the TLS configuration to use with
+	// tls.Client. If nil, the default configuration is used.
+	TLSClientConfig *tls.Config
+
+	// Transport, if not nil, is used to send the requests instead of the
+	// default http.Transport, the transport options above are ignored.
+	// It is useful to stub the network in tests.
+	Transport http.RoundTripper
+
 	// DisableCookieJar specifies whether disable session cookiejar.
 	DisableCookieJar bool
 
@@ -254,6 +680,40 @@ type SessionOptions struct {
 	//
 	// This is unrelated to the similarly named TCP keep-alives.
 	DisableDialKeepAlives bool
+
+	// RaiseForStatus, if true, Session.Send will return a HTTPError
+	// when the status code of response is 4xx or 5xx.
+	RaiseForStatus bool
+
+	// RaiseStatusCodes, if not empty, specifies the status codes that
+	// return a HTTPError instead of 4xx and 5xx.
+	// It only works when RaiseForStatus is true.
+	RaiseStatusCodes []int
+
+	// Netrc, if true, the login and password of the matching machine in
+	// .netrc file are used as BasicAuth for the requests without Auth.
+	Netrc bool
+
+	// NetrcPath is the path of .netrc file, default is DefaultNetrcPath().
+	NetrcPath string
+
+	// Faults, if not empty, are injected to the requests to simulate bad
+	// networks, such as latency, connection resets and timeouts.
+	Faults Faults
+
+	// Debug, if not nil, dumps the wire format of the requests and
+	// responses, including redirects and retries.
+	Debug *DebugDump
+
+	// Headers, if not empty, are the default headers of the session, they
+	// replace the default User-Agent if it is set.
+	Headers http.Header
+
+	// Proxy, if not nil, is the default proxy of the session.
+	Proxy *Proxy
+
+	// Retry, if not nil, the failed requests are retried by it.
+	Retry *Retry
 }
 
 // DefaultSessionOptions return a default SessionOptions object.
@@ -269,6 +729,7 @@ func DefaultSessionOptions() *SessionOptions {
 		ExpectContinueTimeout: 1 * time.Second,
 		DisableCookieJar:      false,
 		DisableDialKeepAlives: false,
+		RaiseForStatus:        false,
 	}
 }
 
@@ -311,12 +772,24 @@ func proxyFunc(req *http.Request) (*url.URL, error) {
 	return envProxyFuncValue(req.URL)
 }
 
-// redirectFunc get redirectNum from request context and check redirect number.
+// redirectFunc get redirectNum and redirectPolicy from request context,
+// check redirect number and apply the redirect policy.
 func redirectFunc(req *http.Request, via []*http.Request) error {
 	redirectNum := req.Context().Value("redirectNum").(int)
+	policy, _ := req.Context().Value("redirectPolicy").(*RedirectPolicy)
 	if len(via) > redirectNum {
+		if policy != nil && policy.ReturnLast {
+			return http.ErrUseLastResponse
+		}
 		err := &RedirectError{redirectNum}
 		return WrapErr(err, "RedirectError")
 	}
+	// never leak the credentials to other hosts.
+	if auth, _ := req.Context().Value("auth").(bool); auth && !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+		req.Header.Del("Authorization")
+	}
+	if policy != nil {
+		return policy.check(req, via)
+	}
 	return nil
 }
@@ -1,8 +1,12 @@
 package direwolf
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -288,3 +292,235 @@ func TestSessionCookies(t *testing.T) {
 		t.Fatal("Session.Cookies() failed.")
 	}
 }
+
+func TestSessionRaiseForStatus(t *testing.T) {
+	ts := newTestResponseServer()
+	defer ts.Close()
+
+	session := NewSession()
+	if _, err := session.Get(ts.URL + "/status/404"); err != nil {
+		t.Fatal("Session RaiseForStatus should be disabled by default.")
+	}
+
+	options := DefaultSessionOptions()
+	options.RaiseForStatus = true
+	session = NewSession(options)
+	_, err := session.Get(ts.URL + "/status/404")
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 404 {
+		t.Fatal("Session RaiseForStatus failed.")
+	}
+	if _, err := session.Get(ts.URL + "/status/200"); err != nil {
+		t.Fatal("Session RaiseForStatus failed.")
+	}
+
+	options.RaiseStatusCodes = []int{429}
+	session = NewSession(options)
+	if _, err := session.Get(ts.URL + "/status/404"); err != nil {
+		t.Fatal("Session RaiseStatusCodes failed.")
+	}
+	_, err = session.Get(ts.URL + "/status/429")
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 429 {
+		t.Fatal("Session RaiseStatusCodes failed.")
+	}
+}
+
+func TestSessionBaseURL(t *testing.T) {
+	ts := newTestSessionServer()
+	defer ts.Close()
+
+	options := DefaultSessionOptions()
+	options.BaseURL = ts.URL
+	session := NewSession(options)
+	resp, err := session.Get("/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "GET" {
+		t.Fatal("Session BaseURL failed.")
+	}
+	resp, err = session.Get("getParams", NewParams("key", "value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "value" || resp.URL != ts.URL+"/getParams?key=value" {
+		t.Fatal("Session BaseURL failed.")
+	}
+
+	// absolute url is not affected.
+	options.BaseURL = "http://direwolf.invalid/api"
+	session = NewSession(options)
+	resp, err = session.Get(ts.URL + "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "GET" {
+		t.Fatal("Session BaseURL failed.")
+	}
+	req, err := session.prepareRequest(&Request{URL: "/users?id=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL != "http://direwolf.invalid/api/users?id=1" {
+		t.Fatal("Session BaseURL failed: ", req.URL)
+	}
+}
+
+func TestSessionWith(t *testing.T) {
+	ts := newTestSessionServer()
+	defer ts.Close()
+
+	session := NewSession()
+	api := session.With(
+		NewParams("key", "default"),
+		NewCookies("key", "default"),
+		NewHeaders("User-Agent", "default"),
+		Timeout(5),
+		RedirectNum(3),
+	)
+	resp, err := api.Get(ts.URL + "/getParams")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "default" {
+		t.Fatal("Session.With Params failed.")
+	}
+	if resp.Request.Timeout != 5 || resp.Request.RedirectNum != 3 {
+		t.Fatal("Session.With failed.")
+	}
+	resp, err = api.Get(ts.URL+"/getParams", NewParams("key", "value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "value" {
+		t.Fatal("Session.With Params failed.")
+	}
+
+	resp, err = api.Get(ts.URL + "/getCookie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "key=default" {
+		t.Fatal("Session.With Cookies failed.")
+	}
+	resp, err = api.Get(ts.URL+"/getCookie", NewCookies("key", "value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "key=value" {
+		t.Fatal("Session.With Cookies failed.")
+	}
+
+	resp, err = api.Get(ts.URL + "/getHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "default" {
+		t.Fatal("Session.With Headers failed.")
+	}
+
+	// original session is not affected.
+	resp, err = session.Get(ts.URL + "/getParams")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "" {
+		t.Fatal("Session.With failed.")
+	}
+}
+
+func TestSessionMerge(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery + "|" + strings.Join(r.Header["Accept"], ",") + "|" + r.Header.Get("Cookie")))
+	}))
+	defer ts.Close()
+
+	session := NewSession()
+	session.Headers.Set("Accept", "text/html")
+	api := session.With(
+		NewParams("token", "xxx", "page", "1"),
+		NewCookies("uid", "1", "lang", "en"),
+	)
+
+	resp, err := api.Get(ts.URL, NewParams("page", "2"), NewHeaders("Accept", "text/plain"), NewCookies("lang", "zh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "token=xxx&page=2|text/plain|uid=1; lang=zh" {
+		t.Fatal("Session merge replace failed: ", resp.Text())
+	}
+
+	merge := &Merge{
+		Headers: map[string]MergeMode{"Accept": MergeAppend},
+		Cookies: map[string]MergeMode{"uid": MergeDelete},
+		Params:  map[string]MergeMode{"page": MergeAppend, "token": MergeDelete},
+	}
+	resp, err = api.Get(ts.URL, NewParams("page", "2"), NewHeaders("Accept", "text/plain"), NewCookies("lang", "zh"), merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "page=1&page=2|text/html,text/plain|lang=zh" {
+		t.Fatal("Session merge failed: ", resp.Text())
+	}
+
+	// Merge can be set as default too.
+	resp, err = api.With(merge).Get(ts.URL, NewHeaders("Accept", "text/plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "page=1|text/html,text/plain|lang=en" {
+		t.Fatal("Session default merge failed: ", resp.Text())
+	}
+}
+
+func TestSessionConcurrentSetters(t *testing.T) {
+	ts := newTestSessionServer()
+	defer ts.Close()
+
+	session := NewSession()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			session.SetHeader("User-Agent", "direwolf-"+strconv.Itoa(i))
+			session.SetProxy(nil)
+			session.SetTimeout(10 + i)
+		}(i)
+		go func() {
+			defer wg.Done()
+			resp, err := session.Get(ts.URL + "/getHeader")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !strings.HasPrefix(resp.Text(), "direwolf") {
+				t.Error("Session setters failed: ", resp.Text())
+			}
+		}()
+	}
+	wg.Wait()
+
+	session.SetHeader("User-Agent", "rotated")
+	if session.GetHeaders().Get("User-Agent") != "rotated" {
+		t.Fatal("Session.SetHeader failed.")
+	}
+	session.AddHeader("Accept", "text/html")
+	session.DelHeader("Accept")
+	if session.GetHeaders().Get("Accept") != "" {
+		t.Fatal("Session.DelHeader failed.")
+	}
+	resp, err := session.Get(ts.URL + "/getHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "rotated" {
+		t.Fatal("Session.SetHeader failed.")
+	}
+	proxy := &Proxy{HTTP: "http://127.0.0.1:1080"}
+	session.SetProxy(proxy)
+	proxy.HTTP = "changed"
+	if session.GetTimeout() < 10 || session.GetProxy().HTTP != "http://127.0.0.1:1080" {
+		t.Fatal("Session setters failed.")
+	}
+}
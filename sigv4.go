@@ -0,0 +1,286 @@
+package direwolf
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+	defaultPresignExpiry = 15 * time.Minute
+)
+
+// sigV4IgnoredHeaders are the headers which are not signed, they may be
+// changed by proxies or the http client.
+var sigV4IgnoredHeaders = map[string]bool{
+	"Authorization":   true,
+	"User-Agent":      true,
+	"X-Amzn-Trace-Id": true,
+	"Expect":          true,
+}
+
+// AWSSigV4 signs requests with the AWS Signature Version 4, one of the Request
+// Options. It can be set as the Session default by Session.With. Please use
+// NewAWSSigV4 to new it.
+//
+// The payload is hashed from the body of the Request, which is Body, JsonBody
+// or PostForm. Set UnsignedPayload to skip it, e.g. uploading large objects to
+// S3. Set Presign to sign the request by the query string instead of the
+// Authorization header, or use PresignURL to get a presigned url only.
+type AWSSigV4 struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // optional, the temporary security credentials.
+	Region       string
+	Service      string
+
+	// UnsignedPayload sends UNSIGNED-PAYLOAD as the payload hash.
+	UnsignedPayload bool
+
+	// Presign signs the request by the query string.
+	Presign bool
+
+	// Expires is the expiry time of the presigned url, default is 15 minutes.
+	Expires time.Duration
+
+	// Now returns the signing time, default is time.Now.
+	Now func() time.Time
+}
+
+// NewAWSSigV4 new a AWSSigV4.
+func NewAWSSigV4(accessKey, secretKey, region, service string) *AWSSigV4 {
+	return &AWSSigV4{AccessKey: accessKey, SecretKey: secretKey, Region: region, Service: service}
+}
+
+// RequestOption interface method, bind request option to request.
+func (options *AWSSigV4) bindRequest(request *Request) error {
+	request.Auth = options
+	return nil
+}
+
+func (options *AWSSigV4) newAuthorizer() authorizer {
+	return options
+}
+
+func (options *AWSSigV4) authorize(req *http.Request) error {
+	return options.sign(req, options.now(), options.Presign)
+}
+
+func (options *AWSSigV4) challenge(resp *Response) (bool, error) {
+	return false, nil
+}
+
+// PresignURL returns the presigned url of the request, which can be requested
+// without the credentials until it is expired.
+func (options *AWSSigV4) PresignURL(method, URL string) (string, error) {
+	req, err := http.NewRequest(method, URL, nil)
+	if err != nil {
+		return "", WrapErr(err, "build Request error, please check request url or request method")
+	}
+	if err := options.sign(req, options.now(), true); err != nil {
+		return "", err
+	}
+	return req.URL.String(), nil
+}
+
+func (options *AWSSigV4) now() time.Time {
+	if options.Now != nil {
+		return options.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// isS3 reports whether the service is S3, which uses a little different rules.
+func (options *AWSSigV4) isS3() bool {
+	return options.Service == "s3"
+}
+
+// sign signs the http.Request by the Authorization header, or by the query
+// string if presign is true.
+func (options *AWSSigV4) sign(req *http.Request, now time.Time, presign bool) error {
+	amzDate := now.Format(sigV4TimeFormat)
+	scope := strings.Join([]string{now.Format("20060102"), options.Region, options.Service, "aws4_request"}, "/")
+
+	payloadHash, err := options.payloadHash(req, presign)
+	if err != nil {
+		return err
+	}
+
+	var signedHeaders []string
+	if presign {
+		signedHeaders = []string{"host"}
+		query := req.URL.Query()
+		query.Set("X-Amz-Algorithm", sigV4Algorithm)
+		query.Set("X-Amz-Credential", options.AccessKey+"/"+scope)
+		query.Set("X-Amz-Date", amzDate)
+		query.Set("X-Amz-Expires", strconv.Itoa(int(options.expires()/time.Second)))
+		query.Set("X-Amz-SignedHeaders", "host")
+		if options.SessionToken != "" {
+			query.Set("X-Amz-Security-Token", options.SessionToken)
+		}
+		req.URL.RawQuery = sigV4Query(query)
+	} else {
+		req.Header.Set("X-Amz-Date", amzDate)
+		if options.SessionToken != "" {
+			req.Header.Set("X-Amz-Security-Token", options.SessionToken)
+		}
+		if (options.isS3() || options.UnsignedPayload) && req.Header.Get("X-Amz-Content-Sha256") == "" {
+			req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		}
+		signedHeaders = append(signedHeaders, "host")
+		for key := range req.Header {
+			if !sigV4IgnoredHeaders[key] {
+				signedHeaders = append(signedHeaders, strings.ToLower(key))
+			}
+		}
+		sort.Strings(signedHeaders)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		options.canonicalURI(req.URL),
+		sigV4Query(req.URL.Query()),
+		sigV4CanonicalHeaders(req, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(options.signingKey(now), []byte(stringToSign)))
+
+	if presign {
+		req.URL.RawQuery += "&X-Amz-Signature=" + signature
+		return nil
+	}
+	req.Header.Set("Authorization", sigV4Algorithm+" Credential="+options.AccessKey+"/"+scope+
+		", SignedHeaders="+strings.Join(signedHeaders, ";")+", Signature="+signature)
+	return nil
+}
+
+// payloadHash returns the hex encoded SHA-256 hash of the request body.
+func (options *AWSSigV4) payloadHash(req *http.Request, presign bool) (string, error) {
+	if presign || options.UnsignedPayload {
+		return sigV4UnsignedPayload, nil
+	}
+	if hash := req.Header.Get("X-Amz-Content-Sha256"); hash != "" {
+		return hash, nil
+	}
+	if req.GetBody == nil {
+		return sha256Hex(nil), nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", WrapErr(err, "read request body failed")
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", WrapErr(err, "read request body failed")
+	}
+	return sha256Hex(data), nil
+}
+
+// signingKey derives the signing key from the secret key.
+func (options *AWSSigV4) signingKey(now time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+options.SecretKey), []byte(now.Format("20060102")))
+	key = hmacSHA256(key, []byte(options.Region))
+	key = hmacSHA256(key, []byte(options.Service))
+	return hmacSHA256(key, []byte("aws4_request"))
+}
+
+func (options *AWSSigV4) expires() time.Duration {
+	if options.Expires > 0 {
+		return options.Expires
+	}
+	return defaultPresignExpiry
+}
+
+// canonicalURI returns the canonical uri of the url. The escaped path is
+// encoded again except S3.
+func (options *AWSSigV4) canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if options.isS3() {
+		return path
+	}
+	return sigV4Escape(path, false)
+}
+
+// sigV4CanonicalHeaders returns the canonical headers of the signed headers.
+func sigV4CanonicalHeaders(req *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, key := range signedHeaders {
+		var value string
+		if key == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		} else {
+			values := req.Header[http.CanonicalHeaderKey(key)]
+			trimmed := make([]string, len(values))
+			for i, v := range values {
+				trimmed[i] = strings.Join(strings.Fields(v), " ")
+			}
+			value = strings.Join(trimmed, ",")
+		}
+		b.WriteString(key + ":" + value + "\n")
+	}
+	return b.String()
+}
+
+// sigV4Query returns the canonical query string, which is sorted by key and
+// value, and encoded by RFC 3986.
+func sigV4Query(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(key, true)+"="+sigV4Escape(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape encodes all of the characters except the unreserved characters.
+// '/' is kept if encodeSlash is false.
+func sigV4Escape(s string, encodeSlash bool) string {
+	const hexChars = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexChars[c>>4])
+		b.WriteByte(hexChars[c&15])
+	}
+	return b.String()
+}
+
+// hmacSHA256 computes the HMAC-SHA256 of data.
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// sha256Hex returns the hex encoded SHA-256 hash of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
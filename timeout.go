@@ -0,0 +1,140 @@
+package direwolf
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Timeouts is the timeouts of each phase of a request, one of the Request
+// Options. It can be set to Session as the default timeouts too. Zero value
+// of a field means no limit for the phase, or keep the Session value.
+//
+//	timeouts := &dw.Timeouts{
+//		Connect:        500 * time.Millisecond,
+//		ResponseHeader: 2 * time.Second,
+//		Total:          5 * time.Second,
+//	}
+type Timeouts struct {
+	// Connect is the time limit to establish a tcp connection.
+	Connect time.Duration
+
+	// TLSHandshake is the time limit of the tls handshake.
+	TLSHandshake time.Duration
+
+	// ResponseHeader is the time limit to wait for the response headers
+	// after the request is written.
+	ResponseHeader time.Duration
+
+	// IdleRead is the time limit between two reads of the response body.
+	IdleRead time.Duration
+
+	// Total is the time limit of the whole exchange, including redirects
+	// and reading the response body. It overrides Timeout.
+	Total time.Duration
+}
+
+// RequestOption interface method, bind request option to request.
+func (options *Timeouts) bindRequest(request *Request) error {
+	request.Timeouts = options
+	return nil
+}
+
+// mergeTimeouts merge Request timeouts and Session timeouts.
+// Request has higher priority.
+func mergeTimeouts(t1, t2 *Timeouts) *Timeouts {
+	t := &Timeouts{}
+	for _, timeouts := range []*Timeouts{t2, t1} {
+		if timeouts == nil {
+			continue
+		}
+		if timeouts.Connect > 0 {
+			t.Connect = timeouts.Connect
+		}
+		if timeouts.TLSHandshake > 0 {
+			t.TLSHandshake = timeouts.TLSHandshake
+		}
+		if timeouts.ResponseHeader > 0 {
+			t.ResponseHeader = timeouts.ResponseHeader
+		}
+		if timeouts.IdleRead > 0 {
+			t.IdleRead = timeouts.IdleRead
+		}
+		if timeouts.Total > 0 {
+			t.Total = timeouts.Total
+		}
+	}
+	return t
+}
+
+// phaseTimer cancels the request when a phase of it is timeout, and
+// records which phase is timeout.
+type phaseTimer struct {
+	mu       sync.Mutex
+	timeouts *Timeouts
+	cancel   context.CancelFunc
+	timer    *time.Timer
+	expired  error
+}
+
+// newPhaseTimer new a phaseTimer, cancel is the cancel function of the request context.
+func newPhaseTimer(timeouts *Timeouts, cancel context.CancelFunc) *phaseTimer {
+	return &phaseTimer{timeouts: timeouts, cancel: cancel}
+}
+
+// start starts timing a phase, kind is the error kind if it is timeout.
+func (p *phaseTimer) start(timeout time.Duration, kind error) {
+	if timeout <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.timer = time.AfterFunc(timeout, func() {
+		p.mu.Lock()
+		p.expired = kind
+		p.mu.Unlock()
+		p.cancel()
+	})
+}
+
+// stop stops timing the current phase.
+func (p *phaseTimer) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+}
+
+// expiredKind returns the error kind of the expired phase, nil if no phase is expired.
+func (p *phaseTimer) expiredKind() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.expired
+}
+
+// idleReader wrap the response body, so that every read is limited by IdleRead.
+func (p *phaseTimer) idleReader(body io.ReadCloser) io.ReadCloser {
+	if p.timeouts.IdleRead <= 0 {
+		return body
+	}
+	return &idleTimeoutReader{ReadCloser: body, timer: p}
+}
+
+// idleTimeoutReader is a response body whose reads are limited by IdleRead.
+type idleTimeoutReader struct {
+	io.ReadCloser
+	timer *phaseTimer
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	r.timer.start(r.timer.timeouts.IdleRead, ErrBodyTimeout)
+	n, err := r.ReadCloser.Read(p)
+	r.timer.stop()
+	return n, err
+}
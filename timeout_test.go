@@ -0,0 +1,108 @@
+package direwolf
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestPhaseTimeoutServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/header":
+			time.Sleep(500 * time.Millisecond)
+			w.Write([]byte("header"))
+		case "/body":
+			w.Write([]byte("part"))
+			w.(http.Flusher).Flush()
+			time.Sleep(500 * time.Millisecond)
+			w.Write([]byte("rest"))
+		}
+	}))
+}
+
+func TestTimeouts(t *testing.T) {
+	ts := newTestPhaseTimeoutServer()
+	defer ts.Close()
+
+	tests := []struct {
+		path     string
+		timeouts *Timeouts
+		kind     error
+	}{
+		{"/header", &Timeouts{ResponseHeader: 100 * time.Millisecond}, ErrHeaderTimeout},
+		{"/header", &Timeouts{Total: 100 * time.Millisecond}, ErrHeaderTimeout},
+		{"/body", &Timeouts{IdleRead: 100 * time.Millisecond}, ErrBodyTimeout},
+		{"/body", &Timeouts{Total: 100 * time.Millisecond}, ErrBodyTimeout},
+	}
+	for _, test := range tests {
+		start := time.Now()
+		_, err := Get(ts.URL+test.path, test.timeouts)
+		if !errors.Is(err, test.kind) || !errors.Is(err, ErrTimeout) {
+			t.Fatal("Timeouts failed: ", test.path, err)
+		}
+		if time.Since(start) > 400*time.Millisecond {
+			t.Fatal("Timeouts failed, request is not canceled in time.")
+		}
+	}
+
+	resp, err := Get(ts.URL+"/body", &Timeouts{ResponseHeader: 100 * time.Millisecond, IdleRead: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "partrest" {
+		t.Fatal("Timeouts failed: ", resp.Text())
+	}
+}
+
+func TestTimeoutsTLSHandshake(t *testing.T) {
+	// a tcp server never finishes the tls handshake.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	_, err = Get("https://"+listener.Addr().String(), &Timeouts{TLSHandshake: 100 * time.Millisecond})
+	if !errors.Is(err, ErrTLSTimeout) {
+		t.Fatal("Timeouts.TLSHandshake failed: ", err)
+	}
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	ts := newTestPhaseTimeoutServer()
+	defer ts.Close()
+
+	session := NewSession()
+	session.SetTimeouts(&Timeouts{ResponseHeader: 100 * time.Millisecond})
+	_, err := session.Get(ts.URL + "/header")
+	if !errors.Is(err, ErrHeaderTimeout) {
+		t.Fatal("Session.Timeouts failed: ", err)
+	}
+
+	// Request has higher priority.
+	resp, err := session.Get(ts.URL+"/header", &Timeouts{ResponseHeader: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "header" {
+		t.Fatal("Session.Timeouts failed.")
+	}
+
+	merged := mergeTimeouts(&Timeouts{Total: time.Second}, &Timeouts{Connect: time.Second, Total: time.Minute})
+	if merged.Connect != time.Second || merged.Total != time.Second {
+		t.Fatal("mergeTimeouts failed: ", merged)
+	}
+}
@@ -0,0 +1,166 @@
+package direwolf
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings is the time spent in each phase of a request. If the request is
+// redirected, it records the phases of the last request.
+type Timings struct {
+	DNSLookup       time.Duration // zero if the connection is reused
+	TCPConnect      time.Duration // zero if the connection is reused
+	TLSHandshake    time.Duration // zero if it is not a https request or the connection is reused
+	FirstByte       time.Duration // time to first byte, from the request start
+	ContentTransfer time.Duration // time to read the response body
+	Total           time.Duration
+	ConnReused      bool   // whether the connection is reused from idle pool
+	RemoteAddr      string // remote ip address and port
+}
+
+// requestTrace records the time points of a request by httptrace.
+type requestTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	done         time.Time
+	connected    bool
+	reused       bool
+	remoteAddr   string
+	timer        *phaseTimer
+}
+
+// newRequestTrace new a requestTrace and start timing. The phases of
+// request will be limited by timer.
+func newRequestTrace(timer *phaseTimer) *requestTrace {
+	return &requestTrace{start: time.Now(), timer: timer}
+}
+
+// clientTrace returns the httptrace.ClientTrace to record the time points.
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) { // a new request begins, reset the phases of the last one.
+			t.mu.Lock()
+			t.start = time.Now()
+			t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+			t.connectStart, t.connectDone = time.Time{}, time.Time{}
+			t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+			t.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dnsDone = time.Now()
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			if t.connectStart.IsZero() { // there may be multiple dials
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+			t.timer.start(t.timer.timeouts.Connect, ErrConnectTimeout)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				return
+			}
+			t.timer.stop()
+			t.mu.Lock()
+			t.connectDone = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+			t.timer.start(t.timer.timeouts.TLSHandshake, ErrTLSTimeout)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			t.timer.stop()
+			if err != nil { // keep tlsDone zero, so that it is still handshaking.
+				return
+			}
+			t.mu.Lock()
+			t.tlsDone = time.Now()
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.connected = true
+			t.reused = info.Reused
+			if info.Conn != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+			t.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.timer.start(t.timer.timeouts.ResponseHeader, ErrHeaderTimeout)
+		},
+		GotFirstResponseByte: func() {
+			t.timer.stop()
+			t.mu.Lock()
+			t.firstByte = time.Now()
+			t.mu.Unlock()
+		},
+	}
+}
+
+// isConnected reports whether the connection has been established.
+func (t *requestTrace) isConnected() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.connected
+}
+
+// isHandshaking reports whether the tls handshake has started but not
+// finished successfully.
+func (t *requestTrace) isHandshaking() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.tlsStart.IsZero() && t.tlsDone.IsZero()
+}
+
+// finish stop timing after the response body is read.
+func (t *requestTrace) finish() {
+	t.mu.Lock()
+	t.done = time.Now()
+	t.mu.Unlock()
+}
+
+// timings calculates the Timings with the recorded time points.
+func (t *requestTrace) timings() Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Timings{
+		DNSLookup:       duration(t.dnsStart, t.dnsDone),
+		TCPConnect:      duration(t.connectStart, t.connectDone),
+		TLSHandshake:    duration(t.tlsStart, t.tlsDone),
+		FirstByte:       duration(t.start, t.firstByte),
+		ContentTransfer: duration(t.firstByte, t.done),
+		Total:           duration(t.start, t.done),
+		ConnReused:      t.reused,
+		RemoteAddr:      t.remoteAddr,
+	}
+}
+
+// duration returns the time elapsed between start and end,
+// returns zero if any of them is not recorded.
+func duration(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
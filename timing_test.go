@@ -0,0 +1,83 @@
+package direwolf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimings(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("timings"))
+	}))
+	defer ts.Close()
+
+	session := NewSession()
+	resp, err := session.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timings := resp.Timings
+	if timings.ConnReused {
+		t.Fatal("Timings.ConnReused failed.")
+	}
+	if timings.TCPConnect <= 0 || timings.FirstByte < 100*time.Millisecond {
+		t.Fatal("Timings failed: ", timings)
+	}
+	if timings.Total < timings.FirstByte || timings.Total != timings.FirstByte+timings.ContentTransfer {
+		t.Fatal("Timings.Total failed: ", timings)
+	}
+	if !strings.HasPrefix(timings.RemoteAddr, "127.0.0.1:") {
+		t.Fatal("Timings.RemoteAddr failed: ", timings.RemoteAddr)
+	}
+
+	resp, err = session.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Timings.ConnReused || resp.Timings.TCPConnect != 0 {
+		t.Fatal("Timings.ConnReused failed: ", resp.Timings)
+	}
+}
+
+func TestTimingsRedirect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			time.Sleep(200 * time.Millisecond)
+			http.Redirect(w, r, "/", 302)
+			return
+		}
+		w.Write([]byte("timings"))
+	}))
+	defer ts.Close()
+
+	resp, err := NewSession().Get(ts.URL + "/redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the last request is recorded.
+	timings := resp.Timings
+	if timings.FirstByte <= 0 || timings.Total >= 200*time.Millisecond {
+		t.Fatal("Timings redirect failed: ", timings)
+	}
+}
+
+func TestTimingsTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("timings"))
+	}))
+	defer ts.Close()
+
+	session := NewSession()
+	session.transport.TLSClientConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig
+	resp, err := session.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Timings.TLSHandshake <= 0 {
+		t.Fatal("Timings.TLSHandshake failed: ", resp.Timings)
+	}
+}
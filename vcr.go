@@ -0,0 +1,335 @@
+package direwolf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// VCRMode is the mode of VCR.
+type VCRMode int
+
+const (
+	// VCRReplay answers the requests from the cassette, without network access.
+	VCRReplay VCRMode = iota
+	// VCRRecord sends the requests, and records the exchanges to the cassette.
+	VCRRecord
+	// VCRAuto replays if the cassette file exists, otherwise records.
+	VCRAuto
+)
+
+// redactedValue replaces the values of the redacted headers.
+const redactedValue = "[REDACTED]"
+
+// defaultRedactHeaders are the secret headers redacted by default.
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// vcrRedactHeaders are the headers redacted by VCR by default. Set-Cookie is
+// recorded, so that the replayed responses still set the cookies.
+var vcrRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// Cassette is the recorded exchanges of VCR.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is the recorded request. Body is base64 encoded if
+// BodyEncoding is "base64".
+type CassetteRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Headers      http.Header `json:"headers"`
+	Body         string      `json:"body"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// CassetteResponse is the recorded response. Body is base64 encoded if
+// BodyEncoding is "base64".
+type CassetteResponse struct {
+	StatusCode   int         `json:"status_code"`
+	Proto        string      `json:"proto"`
+	Headers      http.Header `json:"headers"`
+	Body         string      `json:"body"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// VCR records the exchanges of Session to a cassette file, and replays them
+// in tests, so that the tests need not access the network. Please use NewVCR
+// to new it. Like this:
+//
+//	vcr, err := dw.NewVCR("testdata/api.json", dw.VCRAuto)
+//	session.WrapTransport(vcr.Wrap)
+//	defer vcr.Save()
+//
+// In replay mode, the requests are matched by method, url, body and the
+// MatchHeaders. If there are several matching interactions, they are
+// returned in order, and the last one is repeated. ErrCassetteNotFound is
+// returned if there is no matching interaction. The mode can be switched by
+// SetMode, so that the recorded exchanges can be replayed at once.
+type VCR struct {
+	Path string
+
+	// MatchHeaders are the headers which must be the same when matching.
+	// The redacted headers can not be matched.
+	MatchHeaders []string
+
+	// RedactHeaders are the secret headers whose values are replaced when
+	// recording. Default is Authorization, Proxy-Authorization, Cookie and
+	// X-Api-Key, set it to an empty slice to record all of them. Set-Cookie
+	// is not redacted by default, the replayed responses can not set the
+	// cookies if it is redacted.
+	RedactHeaders []string
+
+	mu       sync.Mutex
+	mode     VCRMode
+	cassette *Cassette
+	used     []bool
+}
+
+// NewVCR new a VCR, the cassette is loaded in replay mode.
+func NewVCR(path string, mode VCRMode) (*VCR, error) {
+	vcr := &VCR{Path: path, mode: mode, cassette: &Cassette{}}
+	if mode == VCRAuto {
+		if _, err := os.Stat(path); err == nil {
+			vcr.mode = VCRReplay
+		} else {
+			vcr.mode = VCRRecord
+		}
+	}
+	if vcr.mode == VCRReplay {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, WrapErr(err, "read cassette failed")
+		}
+		if err := json.Unmarshal(data, vcr.cassette); err != nil {
+			return nil, WrapErr(err, "decode cassette failed")
+		}
+	}
+	return vcr, nil
+}
+
+// SetMode switches the mode of VCR, it must be VCRRecord or VCRReplay. The
+// interactions recorded before are kept, so they can be replayed.
+func (vcr *VCR) SetMode(mode VCRMode) {
+	vcr.mu.Lock()
+	defer vcr.mu.Unlock()
+	vcr.mode = mode
+}
+
+// GetMode returns the mode of VCR, it is never VCRAuto.
+func (vcr *VCR) GetMode() VCRMode {
+	vcr.mu.Lock()
+	defer vcr.mu.Unlock()
+	return vcr.mode
+}
+
+// Wrap returns a http.RoundTripper which records the requests sent by next,
+// or replays them from the cassette.
+func (vcr *VCR) Wrap(next http.RoundTripper) http.RoundTripper {
+	return &vcrTransport{vcr: vcr, next: next}
+}
+
+// Save writes the recorded cassette to Path, it does nothing in replay mode.
+func (vcr *VCR) Save() error {
+	vcr.mu.Lock()
+	defer vcr.mu.Unlock()
+	if vcr.mode != VCRRecord {
+		return nil
+	}
+	data, err := json.MarshalIndent(vcr.cassette, "", "  ")
+	if err != nil {
+		return WrapErr(err, "encode cassette failed")
+	}
+	if err := os.MkdirAll(filepath.Dir(vcr.Path), 0755); err != nil {
+		return WrapErr(err, "create cassette directory failed")
+	}
+	if err := ioutil.WriteFile(vcr.Path, data, 0644); err != nil {
+		return WrapErr(err, "write cassette failed")
+	}
+	return nil
+}
+
+// match returns the matching interaction of the request.
+func (vcr *VCR) match(req *http.Request, body []byte) *Interaction {
+	vcr.mu.Lock()
+	defer vcr.mu.Unlock()
+	// the interactions may be recorded after the VCR is created.
+	for len(vcr.used) < len(vcr.cassette.Interactions) {
+		vcr.used = append(vcr.used, false)
+	}
+	last := -1
+	for i, interaction := range vcr.cassette.Interactions {
+		recorded := interaction.Request
+		if recorded.Method != req.Method || recorded.URL != req.URL.String() {
+			continue
+		}
+		recordedBody, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+		if err != nil || !bytes.Equal(recordedBody, body) {
+			continue
+		}
+		matched := true
+		for _, key := range vcr.MatchHeaders {
+			key = http.CanonicalHeaderKey(key)
+			if strings.Join(recorded.Headers[key], ",") != strings.Join(req.Header[key], ",") {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if !vcr.used[i] {
+			vcr.used[i] = true
+			return interaction
+		}
+		last = i
+	}
+	if last >= 0 {
+		return vcr.cassette.Interactions[last]
+	}
+	return nil
+}
+
+// record adds the exchange to the cassette, the secret headers are redacted.
+func (vcr *VCR) record(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte) {
+	respHeader := vcr.redact(resp.Header)
+	if resp.Uncompressed { // the body has been decoded by the transport.
+		respHeader.Del("Content-Encoding")
+	}
+	interaction := &Interaction{
+		Request: CassetteRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: vcr.redact(req.Header),
+		},
+		Response: CassetteResponse{
+			StatusCode: resp.StatusCode,
+			Proto:      resp.Proto,
+			Headers:    respHeader,
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeBody(reqBody)
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeBody(respBody)
+
+	vcr.mu.Lock()
+	defer vcr.mu.Unlock()
+	vcr.cassette.Interactions = append(vcr.cassette.Interactions, interaction)
+}
+
+// redact returns a copy of the header, the secret headers are redacted.
+func (vcr *VCR) redact(header http.Header) http.Header {
+	keys := vcr.RedactHeaders
+	if keys == nil {
+		keys = vcrRedactHeaders
+	}
+	return redactHeaders(header, keys)
+}
+
+// redactHeaders returns a copy of the header, the values of keys are redacted.
+func redactHeaders(header http.Header, keys []string) http.Header {
+	h := header.Clone()
+	for _, key := range keys {
+		key = http.CanonicalHeaderKey(key)
+		for i := range h[key] {
+			h[key][i] = redactedValue
+		}
+	}
+	return h
+}
+
+// vcrTransport records or replays the requests of VCR.
+type vcrTransport struct {
+	vcr  *VCR
+	next http.RoundTripper
+}
+
+func (t *vcrTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.vcr.GetMode() == VCRReplay {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		interaction := t.vcr.match(req, body)
+		if interaction == nil {
+			return nil, WrapErrf(ErrCassetteNotFound, "%s %s", req.Method, req.URL)
+		}
+		return interaction.Response.httpResponse(req)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	content, readErr := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if readErr != nil {
+		return nil, WrapErr(readErr, "read Response.Body failed")
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(content))
+	t.vcr.record(req, body, resp, content)
+	return resp, nil
+}
+
+// httpResponse converts the recorded response to http.Response.
+func (r *CassetteResponse) httpResponse(req *http.Request) (*http.Response, error) {
+	content, err := decodeBody(r.Body, r.BodyEncoding)
+	if err != nil {
+		return nil, WrapErr(err, "decode cassette body failed")
+	}
+	header := r.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	// Content-Encoding is kept, the body is still encoded if it is recorded.
+	header.Set("Content-Length", strconv.Itoa(len(content)))
+	proto := r.Proto
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		proto, major, minor = "HTTP/1.1", 1, 1
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(r.StatusCode) + " " + http.StatusText(r.StatusCode),
+		StatusCode:    r.StatusCode,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(content)),
+		ContentLength: int64(len(content)),
+		Request:       req,
+	}, nil
+}
+
+// encodeBody encodes the body as text, or base64 if it is not valid UTF-8.
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// decodeBody decodes the body encoded by encodeBody.
+func decodeBody(body, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
@@ -0,0 +1,149 @@
+package direwolf
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVCR(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=direwolf")
+		w.Write([]byte(r.Method + " " + r.Header.Get("X-Version") + " " + string(body)))
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "direwolf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassettes", "api.json")
+
+	// record the exchanges.
+	vcr, err := NewVCR(path, VCRAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vcr.GetMode() != VCRRecord {
+		t.Fatal("VCRAuto record mode failed.")
+	}
+	session := NewSession()
+	session.SetHeader("Authorization", "Bearer secret-token")
+	session.WrapTransport(vcr.Wrap)
+	for _, version := range []string{"1", "2"} {
+		if _, err := session.Post(ts.URL+"/items", Body("a"), NewHeaders("X-Version", version)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := session.Post(ts.URL+"/items", Body("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := vcr.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") || !strings.Contains(string(data), redactedValue) {
+		t.Fatal("VCR redact failed.")
+	}
+	if !strings.Contains(string(data), "session=direwolf") {
+		t.Fatal("VCR Set-Cookie is not recorded.")
+	}
+
+	// replay the exchanges without network.
+	ts.Close()
+	vcr, err = NewVCR(path, VCRAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vcr.MatchHeaders = []string{"X-Version"}
+	session = NewSession()
+	session.WrapTransport(vcr.Wrap)
+	tests := []struct {
+		body     string
+		version  string
+		expected string
+	}{
+		{"b", "", "POST  b"},
+		{"a", "2", "POST 2 a"},
+		{"a", "1", "POST 1 a"},
+		{"a", "1", "POST 1 a"}, // the last matching one is repeated.
+	}
+	for _, test := range tests {
+		resp, err := session.Post(ts.URL+"/items", Body(test.body), NewHeaders("X-Version", test.version))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Text() != test.expected {
+			t.Fatal("VCR replay failed: ", resp.Text())
+		}
+	}
+	if hits != 3 {
+		t.Fatal("VCR replay accessed the network.")
+	}
+	if cookies := session.Cookies(ts.URL); len(cookies) != 1 || cookies[0].Value != "direwolf" {
+		t.Fatal("VCR replay cookies failed.")
+	}
+
+	_, err = session.Post(ts.URL+"/items", Body("c"))
+	if !errors.Is(err, ErrCassetteNotFound) {
+		t.Fatal("VCR not found failed: ", err)
+	}
+	if _, err := NewVCR(filepath.Join(dir, "missing.json"), VCRReplay); err == nil {
+		t.Fatal("VCR missing cassette failed.")
+	}
+}
+
+func TestVCRSetMode(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(testGzipBody)
+			return
+		}
+		w.Write([]byte("recorded"))
+	}))
+
+	vcr, err := NewVCR("unused.json", VCRRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := NewSession()
+	session.WrapTransport(vcr.Wrap)
+	if _, err := session.Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Get(ts.URL+"/gzip", NewHeaders("Accept-Encoding", "gzip")); err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+
+	// replay the exchanges recorded just now.
+	vcr.SetMode(VCRReplay)
+	resp, err := session.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "recorded" {
+		t.Fatal("VCR.SetMode replay failed: ", resp.Text())
+	}
+	// the body is not decoded by the transport, so it is still encoded.
+	resp, err = session.Get(ts.URL+"/gzip", NewHeaders("Accept-Encoding", "gzip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Headers.Get("Content-Encoding") != "gzip" || !bytes.Equal(resp.Content, testGzipBody) {
+		t.Fatal("VCR Content-Encoding failed.")
+	}
+}